@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -11,17 +12,42 @@ import (
 
 // Config holds all environment configurations
 type Config struct {
-	Port            string
-	JWTSecret       string
-	JWTExpiration   time.Duration
-	RefreshSecret   string
-	RefreshDuration time.Duration
-	DBHost          string
-	DBPort          string
-	DBUser          string
-	DBPassword      string
-	DBName          string
-	DBSSLMode       string
+	Port              string
+	JWTKeysDir        string // Directory of PEM signing keys, named <kid>.pem
+	JWTActiveKeyID    string // Key that signs new tokens; defaults to the last kid
+	JWTEphemeralKeys  bool   // Generate a throwaway key when JWTKeysDir is empty, even in release mode
+	JWTExpiration     time.Duration
+	RefreshDuration   time.Duration
+	DBHost            string
+	DBPort            string
+	DBUser            string
+	DBPassword        string
+	DBName            string
+	DBSSLMode         string
+	AppURL            string // Base URL used in links sent by email
+	EmailVerification string // "off", "limit" (read-only until verified) or "block"
+	MailDriver        string // "smtp" or "log"
+	MailFrom          string
+	MailLogPath       string // File the log mailer appends to; empty logs to stdout
+	SMTPHost          string
+	SMTPPort          string
+	SMTPUsername      string
+	SMTPPassword      string
+	OIDCProviders     map[string]*OIDCProvider // Keyed by provider name
+	ThrottleStore     string                   // "memory" or "postgres" (shared between instances)
+	TrustedProxies    []string                 // Proxies whose X-Forwarded-For is believed; empty trusts none
+	DeletionGrace     time.Duration            // Time before a deleted account is purged
+	ArchiveRetention  time.Duration            // Time before an archived habit is purged; zero keeps them
+}
+
+// OIDCProvider holds the settings of an OpenID Connect login provider
+type OIDCProvider struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
 }
 
 // LoadConfig loads the environment variables into a Config struct
@@ -43,21 +69,95 @@ func LoadConfig() *Config {
 		refreshExp = 7
 	}
 
+	// Ephemeral signing keys are always allowed outside release mode
+	ephemeralKeys, _ := strconv.ParseBool(os.Getenv("JWT_EPHEMERAL_KEYS"))
+
+	// Account deletion grace period in days, default 14 days
+	deletionGrace, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"))
+	if err != nil || deletionGrace < 0 {
+		deletionGrace = 14
+	}
+
+	// Archived habit retention in days, default 90 days; 0 keeps them
+	archiveRetention, err := strconv.Atoi(os.Getenv("ARCHIVED_HABIT_RETENTION_DAYS"))
+	if err != nil || archiveRetention < 0 {
+		archiveRetention = 90
+	}
+
 	return &Config{
-		Port:            getEnvWithDefault("PORT", "8080"),
-		JWTSecret:       getEnvWithDefault("JWT_SECRET", "your-secret-key"),
-		JWTExpiration:   time.Duration(jwtExp) * time.Hour,
-		RefreshSecret:   getEnvWithDefault("REFRESH_SECRET", "your-refresh-secret-key"),
-		RefreshDuration: time.Duration(refreshExp) * 24 * time.Hour,
-		DBHost:          getEnvWithDefault("DB_HOST", "localhost"),
-		DBPort:          getEnvWithDefault("DB_PORT", "5432"),
-		DBUser:          getEnvWithDefault("DB_USER", "postgres"),
-		DBPassword:      getEnvWithDefault("DB_PASSWORD", "postgres"),
-		DBName:          getEnvWithDefault("DB_NAME", "habitrack"),
-		DBSSLMode:       getEnvWithDefault("DB_SSL_MODE", "disable"),
+		Port:              getEnvWithDefault("PORT", "8080"),
+		JWTKeysDir:        getEnvWithDefault("JWT_KEYS_DIR", "keys"),
+		JWTActiveKeyID:    os.Getenv("JWT_ACTIVE_KID"),
+		JWTEphemeralKeys:  ephemeralKeys,
+		JWTExpiration:     time.Duration(jwtExp) * time.Hour,
+		RefreshDuration:   time.Duration(refreshExp) * 24 * time.Hour,
+		DBHost:            getEnvWithDefault("DB_HOST", "localhost"),
+		DBPort:            getEnvWithDefault("DB_PORT", "5432"),
+		DBUser:            getEnvWithDefault("DB_USER", "postgres"),
+		DBPassword:        getEnvWithDefault("DB_PASSWORD", "postgres"),
+		DBName:            getEnvWithDefault("DB_NAME", "habitrack"),
+		DBSSLMode:         getEnvWithDefault("DB_SSL_MODE", "disable"),
+		AppURL:            getEnvWithDefault("APP_URL", "http://localhost:8080"),
+		EmailVerification: getEnvWithDefault("EMAIL_VERIFICATION", "off"),
+		MailDriver:        getEnvWithDefault("MAIL_DRIVER", "log"),
+		MailFrom:          getEnvWithDefault("MAIL_FROM", "HabiTrack <no-reply@habitrack.local>"),
+		MailLogPath:       os.Getenv("MAIL_LOG_PATH"),
+		SMTPHost:          os.Getenv("SMTP_HOST"),
+		SMTPPort:          getEnvWithDefault("SMTP_PORT", "587"),
+		SMTPUsername:      os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:      os.Getenv("SMTP_PASSWORD"),
+		OIDCProviders:     loadOIDCProviders(),
+		ThrottleStore:     getEnvWithDefault("THROTTLE_STORE", "memory"),
+		TrustedProxies:    splitList(os.Getenv("TRUSTED_PROXIES")),
+		DeletionGrace:     time.Duration(deletionGrace) * 24 * time.Hour,
+		ArchiveRetention:  time.Duration(archiveRetention) * 24 * time.Hour,
 	}
 }
 
+// loadOIDCProviders reads the providers listed in OIDC_PROVIDERS. Each name
+// is configured through OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET,
+// _REDIRECT_URL and optionally _SCOPES (space separated).
+func loadOIDCProviders() map[string]*OIDCProvider {
+	providers := make(map[string]*OIDCProvider)
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := &OIDCProvider{
+			Name:         name,
+			IssuerURL:    strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(getEnvWithDefault(prefix+"SCOPES", "openid email profile")),
+		}
+
+		if provider.IssuerURL == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			log.Printf("Skipping OIDC provider %q: issuer, client ID and redirect URL are required", name)
+			continue
+		}
+
+		providers[name] = provider
+	}
+
+	return providers
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // Get environment variable or return default value
 func getEnvWithDefault(key, defaultValue string) string {
 	value := os.Getenv(key)
@@ -65,4 +165,4 @@ func getEnvWithDefault(key, defaultValue string) string {
 		return defaultValue
 	}
 	return value
-}
\ No newline at end of file
+}
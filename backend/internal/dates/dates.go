@@ -0,0 +1,176 @@
+package dates
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Layout is the format of calendar dates in requests and responses
+const Layout = "2006-01-02"
+
+// Days of the week a user's week can start on
+const (
+	WeekStartMonday   = "monday"
+	WeekStartSunday   = "sunday"
+	WeekStartSaturday = "saturday"
+)
+
+// Calendar dates are represented as time.Time values at midnight UTC, which
+// is also how DATE columns are read back from Postgres. This keeps a date
+// from shifting to a neighbouring day when it is stored or compared.
+
+// Date is a calendar date that is written as YYYY-MM-DD in JSON and stored
+// in a DATE column
+type Date struct {
+	time.Time
+}
+
+// NewDate returns the calendar date of t as written
+func NewDate(t time.Time) Date {
+	return Date{Of(t)}
+}
+
+// MarshalJSON writes the date as YYYY-MM-DD
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Format(Layout))
+}
+
+// UnmarshalJSON reads a YYYY-MM-DD date. Full RFC 3339 timestamps sent by
+// older clients are accepted too, as the date they are written on.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	t, err := Parse(s)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, s); err != nil {
+			return errors.New("invalid date format (use YYYY-MM-DD)")
+		}
+	}
+
+	*d = NewDate(t)
+	return nil
+}
+
+// Value stores the date as midnight UTC
+func (d Date) Value() (driver.Value, error) {
+	return d.Time, nil
+}
+
+// LoadLocation returns the IANA time zone with the given name, falling back
+// to UTC if it is empty or unknown
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// IsValidTimezone reports whether name is a loadable IANA time zone
+func IsValidTimezone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// In returns the calendar date that the instant t falls on in loc
+func In(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Today returns the current calendar date in loc
+func Today(loc *time.Location) time.Time {
+	return In(time.Now(), loc)
+}
+
+// Of returns the calendar date of t as written, ignoring its time of day
+// and zone
+func Of(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Parse parses a YYYY-MM-DD calendar date
+func Parse(s string) (time.Time, error) {
+	return time.Parse(Layout, s)
+}
+
+// Weekday returns the day a week starts on for a week_start setting
+func Weekday(weekStart string) time.Weekday {
+	switch weekStart {
+	case WeekStartSunday:
+		return time.Sunday
+	case WeekStartSaturday:
+		return time.Saturday
+	default:
+		return time.Monday
+	}
+}
+
+// StartOfWeek returns the first day of the week containing date
+func StartOfWeek(date time.Time, weekStart string) time.Time {
+	offset := (int(date.Weekday()) - int(Weekday(weekStart)) + 7) % 7
+	return Of(date).AddDate(0, 0, -offset)
+}
+
+// StartOfMonth returns the first day of the month containing date
+func StartOfMonth(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// StartOfYear returns the first day of the year containing date
+func StartOfYear(date time.Time) time.Time {
+	return time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Periods that dates can be grouped into
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+	Yearly  = "yearly"
+)
+
+// IsValidPeriod reports whether period names a known period
+func IsValidPeriod(period string) bool {
+	return period == Daily || period == Weekly || period == Monthly || period == Yearly
+}
+
+// PeriodBounds returns the first and last day of the calendar period that
+// contains date. Weeks begin on the given week_start day.
+func PeriodBounds(period string, date time.Time, weekStart string) (time.Time, time.Time) {
+	switch period {
+	case Weekly:
+		start := StartOfWeek(date, weekStart)
+		return start, start.AddDate(0, 0, 6)
+	case Monthly:
+		start := StartOfMonth(date)
+		return start, start.AddDate(0, 1, -1)
+	case Yearly:
+		start := StartOfYear(date)
+		return start, start.AddDate(1, 0, -1)
+	default:
+		day := Of(date)
+		return day, day
+	}
+}
+
+// DaysBetween returns the number of days from start to end, inclusive
+func DaysBetween(start, end time.Time) int {
+	if end.Before(start) {
+		return 0
+	}
+	return int(Of(end).Sub(Of(start)).Hours()/24) + 1
+}
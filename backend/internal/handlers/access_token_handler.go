@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/config"
+	"gitlab.com/KARSTERRR/habitrack/models"
+	"gitlab.com/KARSTERRR/habitrack/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// AccessTokenHandler handles personal access token requests
+type AccessTokenHandler struct {
+	DB     *sql.DB
+	Config *config.Config
+}
+
+// NewAccessTokenHandler creates a new personal access token handler
+func NewAccessTokenHandler(db *sql.DB, cfg *config.Config) *AccessTokenHandler {
+	return &AccessTokenHandler{DB: db, Config: cfg}
+}
+
+// CreateAccessTokenRequest is the request body for creating a personal access token
+type CreateAccessTokenRequest struct {
+	Name          string   `json:"name" validate:"required,min=1,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"min=0,max=3650"` // 0 means no expiry
+}
+
+// UpdateAccessTokenRequest is the request body for renaming a personal access token
+type UpdateAccessTokenRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+// CreateToken creates a personal access token. The token itself is returned
+// only in this response.
+func (h *AccessTokenHandler) CreateToken(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check and de-duplicate the requested scopes
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]bool)
+	for _, scope := range req.Scopes {
+		if !models.IsValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope: " + scope, "valid_scopes": models.ValidScopes})
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	plainToken := models.PersonalAccessTokenPrefix + secret
+
+	token := &models.PersonalAccessToken{
+		UserID:      userID.(int64),
+		Name:        req.Name,
+		TokenHash:   utils.HashToken(plainToken),
+		TokenPrefix: plainToken[:len(models.PersonalAccessTokenPrefix)+4],
+		Scopes:      scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expires
+	}
+
+	tokenRepo := models.NewAccessTokenRepository(h.DB)
+	if err := tokenRepo.Create(token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":        plainToken,
+		"access_token": token,
+	})
+}
+
+// ListTokens lists the current user's personal access tokens
+func (h *AccessTokenHandler) ListTokens(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	tokenRepo := models.NewAccessTokenRepository(h.DB)
+	tokens, err := tokenRepo.GetAllByUser(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// GetToken retrieves a personal access token by ID
+func (h *AccessTokenHandler) GetToken(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse token ID from URL
+	tokenID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	tokenRepo := models.NewAccessTokenRepository(h.DB)
+	token, err := tokenRepo.GetByID(tokenID, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+// UpdateToken renames a personal access token
+func (h *AccessTokenHandler) UpdateToken(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse token ID from URL
+	tokenID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	var req UpdateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokenRepo := models.NewAccessTokenRepository(h.DB)
+	if err := tokenRepo.UpdateName(tokenID, userID.(int64), req.Name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+
+	token, err := tokenRepo.GetByID(tokenID, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+// RevokeToken revokes a personal access token
+func (h *AccessTokenHandler) RevokeToken(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse token ID from URL
+	tokenID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	tokenRepo := models.NewAccessTokenRepository(h.DB)
+	if err := tokenRepo.Revoke(tokenID, userID.(int64)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked successfully"})
+}
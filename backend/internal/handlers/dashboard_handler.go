@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+	"gitlab.com/KARSTERRR/habitrack/internal/streak"
+	"gitlab.com/KARSTERRR/habitrack/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DashboardHandler handles the home screen summary
+type DashboardHandler struct {
+	DB *sql.DB
+}
+
+// NewDashboardHandler creates a new dashboard handler
+func NewDashboardHandler(db *sql.DB) *DashboardHandler {
+	return &DashboardHandler{DB: db}
+}
+
+// DashboardHabit is a habit together with its progress on the dashboard day
+type DashboardHabit struct {
+	Habit       *models.Habit `json:"habit"`
+	Completed   bool          `json:"completed"` // Completed on the dashboard day
+	Status      string        `json:"status"`    // Status tracked on the dashboard day; empty if none
+	Scheduled   bool          `json:"scheduled"` // Due on the dashboard day under the habit's schedule
+	Value       float64       `json:"value"`
+	Progress    GoalProgress  `json:"progress"`
+	Streak      streak.Run    `json:"streak"`
+	StreakUnit  string        `json:"streak_unit"`
+	DaysClean   *int          `json:"days_clean,omitempty"` // Habits to break: days since the latest relapse
+	GoalReached bool          `json:"goal_reached"`         // Goal met for the current period
+}
+
+// GoalProgress counts completions toward a habit's goal in its current period
+type GoalProgress struct {
+	Period    string    `json:"period"` // The habit's frequency unit, daily for day-based schedules
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	Count     int       `json:"count"`
+	Goal      int       `json:"goal"`
+}
+
+// CompletionSummary is the overall completion across habits for a window
+type CompletionSummary struct {
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	Completed int       `json:"completed"`
+	Total     int       `json:"total"`
+	Rate      float64   `json:"rate"` // Percentage of completion
+}
+
+// DashboardResponse is the response body of the dashboard
+type DashboardResponse struct {
+	Date   time.Time          `json:"date"`
+	Habits []*DashboardHabit  `json:"habits"`
+	Day    *CompletionSummary `json:"day"`
+	Week   *CompletionSummary `json:"week"`
+}
+
+// GetDashboard returns every active habit with its progress on a day, plus
+// overall completion for that day and its week
+func (h *DashboardHandler) GetDashboard(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	user, err := userRepo.GetByID(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	loc := user.Location()
+
+	// Parse the day from query parameters, defaulting to today
+	date := dates.Today(loc)
+	if dateStr := c.Query("date"); dateStr != "" {
+		date, err = dates.Parse(dateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format (use YYYY-MM-DD)"})
+			return
+		}
+	}
+
+	weekStart, _ := dates.PeriodBounds(dates.Weekly, date, user.WeekStart)
+	monthStart, _ := dates.PeriodBounds(dates.Monthly, date, user.WeekStart)
+
+	// Load everything with one query each
+	habitRepo := models.NewHabitRepository(h.DB)
+	habits, err := habitRepo.GetAllByUser(user.ID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve habits"})
+		return
+	}
+
+	dashboardRepo := models.NewDashboardRepository(h.DB)
+	progress, err := dashboardRepo.GetProgress(user.ID, date, weekStart, monthStart)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tracking records"})
+		return
+	}
+
+	completed, err := dashboardRepo.GetCompletedDates(user.ID, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tracking records"})
+		return
+	}
+
+	relapses, err := dashboardRepo.GetRelapseDates(user.ID, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tracking records"})
+		return
+	}
+
+	skipped, err := dashboardRepo.GetSkippedDates(user.ID, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tracking records"})
+		return
+	}
+
+	freezeRepo := models.NewFreezeRepository(h.DB)
+	frozen, err := freezeRepo.GetFrozenDates(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve streak freezes"})
+		return
+	}
+
+	pauseRepo := models.NewPauseRepository(h.DB)
+	pauses, err := pauseRepo.GetAllByUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pauses"})
+		return
+	}
+
+	response := buildDashboard(user, date, &dashboardData{
+		habits:    habits,
+		progress:  progress,
+		completed: completed,
+		relapses:  relapses,
+		skipped:   skipped,
+		frozen:    frozen,
+		pauses:    pauses,
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// dashboardData is what the dashboard of a day is built from, each part
+// loaded for all of the user's active habits at once
+type dashboardData struct {
+	habits    []*models.Habit
+	progress  map[int64]*models.HabitProgress
+	completed map[int64][]time.Time
+	relapses  map[int64][]time.Time
+	skipped   map[int64][]time.Time
+	frozen    map[int64][]time.Time
+	pauses    []*models.Pause
+}
+
+// buildDashboard works out each habit's progress on a day and the overall
+// completion for that day and its week
+func buildDashboard(user *models.User, date time.Time, data *dashboardData) *DashboardResponse {
+	loc := user.Location()
+	weekStart, weekEnd := dates.PeriodBounds(dates.Weekly, date, user.WeekStart)
+	monthStart, monthEnd := dates.PeriodBounds(dates.Monthly, date, user.WeekStart)
+
+	response := &DashboardResponse{
+		Date:   date,
+		Habits: make([]*DashboardHabit, 0, len(data.habits)),
+		Day:    &CompletionSummary{StartDate: date, EndDate: date},
+		Week:   &CompletionSummary{StartDate: weekStart, EndDate: weekEnd},
+	}
+
+	for _, habit := range data.habits {
+		// Skip habits that didn't exist yet on the requested day
+		firstDay := habit.FirstDay(loc, data.completed[habit.ID], data.relapses[habit.ID], data.skipped[habit.ID])
+		if firstDay.After(date) {
+			continue
+		}
+
+		// Skip habits that had already ended
+		if habit.EndDate != nil && habit.EndDate.Before(date) {
+			continue
+		}
+
+		p, ok := data.progress[habit.ID]
+		if !ok {
+			p = &models.HabitProgress{HabitID: habit.ID}
+		}
+
+		rule := habit.Rule(user, data.pauses, data.skipped[habit.ID])
+		rule.Frozen = streak.DateSet(data.frozen[habit.ID])
+
+		// A habit to break is completed on every expected day without a
+		// relapse
+		history := data.completed[habit.ID]
+		var daysClean *int
+		if habit.IsNegative() {
+			history = streak.CleanDays(rule, data.relapses[habit.ID], firstDay, date)
+			p.CompletedToday = len(history) > 0 && history[len(history)-1].Equal(date)
+			p.WeekCount = countBetween(history, weekStart, date)
+			p.MonthCount = countBetween(history, monthStart, date)
+
+			lastDay := firstDay
+			if r := data.relapses[habit.ID]; len(r) > 0 {
+				lastDay = dates.Of(r[len(r)-1])
+			}
+			days := dates.DaysBetween(lastDay, date) - 1
+			daysClean = &days
+		}
+
+		streaks := streak.Compute(rule, history, firstDay, date)
+
+		item := &DashboardHabit{
+			Habit:      habit,
+			Completed:  p.CompletedToday,
+			Status:     p.StatusToday,
+			Scheduled:  rule.IsScheduled(date),
+			Value:      p.ValueToday,
+			Streak:     streaks.Current,
+			StreakUnit: streaks.Unit,
+			DaysClean:  daysClean,
+		}
+
+		// Progress toward the goal of the habit's own period. Day-based
+		// schedules expect nothing on unscheduled days.
+		count := 0
+		if p.CompletedToday {
+			count = 1
+		}
+		switch {
+		case rule.IsDayBased():
+			goal := 0
+			if item.Scheduled {
+				goal = 1
+			}
+			item.Progress = GoalProgress{Period: dates.Daily, StartDate: date, EndDate: date, Count: count, Goal: goal}
+		case habit.FrequencyUnit == dates.Weekly:
+			item.Progress = GoalProgress{Period: dates.Weekly, StartDate: weekStart, EndDate: weekEnd, Count: p.WeekCount, Goal: max(habit.Goal, 1)}
+		case habit.FrequencyUnit == dates.Monthly:
+			item.Progress = GoalProgress{Period: dates.Monthly, StartDate: monthStart, EndDate: monthEnd, Count: p.MonthCount, Goal: max(habit.Goal, 1)}
+		default:
+			item.Progress = GoalProgress{Period: dates.Daily, StartDate: date, EndDate: date, Count: count, Goal: 1}
+		}
+		item.GoalReached = item.Progress.Count >= item.Progress.Goal
+
+		// Every habit due on the day counts toward it
+		if item.Scheduled {
+			response.Day.Total++
+			if p.CompletedToday {
+				response.Day.Completed++
+			}
+		}
+
+		// Toward the week, weekly habits are expected up to their goal and
+		// other habits on each elapsed day of the week they are due. Monthly
+		// habits have no weekly target and are left out.
+		switch {
+		case rule.IsDayBased() || habit.FrequencyUnit == dates.Daily:
+			from := weekStart
+			if firstDay.After(from) {
+				from = firstDay
+			}
+			expected, met := streak.Expected(rule, history, from, date)
+			response.Week.Total += expected
+			response.Week.Completed += met
+		case habit.FrequencyUnit == dates.Weekly:
+			response.Week.Total += item.Progress.Goal
+			response.Week.Completed += min(p.WeekCount, item.Progress.Goal)
+		}
+
+		response.Habits = append(response.Habits, item)
+	}
+
+	response.Day.Rate = completionRate(response.Day.Completed, response.Day.Total)
+	response.Week.Rate = completionRate(response.Week.Completed, response.Week.Total)
+
+	return response
+}
+
+// countBetween counts the dates from from to to
+func countBetween(days []time.Time, from, to time.Time) int {
+	count := 0
+	for _, day := range days {
+		if !day.Before(from) && !day.After(to) {
+			count++
+		}
+	}
+	return count
+}
+
+// completionRate returns completed as a percentage of total
+func completionRate(completed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(completed) / float64(total) * 100.0
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+	"gitlab.com/KARSTERRR/habitrack/models"
+)
+
+// date parses a YYYY-MM-DD date, failing the test on a typo
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := dates.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestBuildDashboard(t *testing.T) {
+	// 2026-03-04 is a Wednesday
+	user := &models.User{ID: 1, WeekStart: "monday"}
+	day := date(t, "2026-03-04")
+
+	daily := &models.Habit{ID: 1, Type: models.PositiveHabit, FrequencyUnit: dates.Daily, CreatedAt: date(t, "2026-03-01")}
+	weekly := &models.Habit{ID: 2, Type: models.PositiveHabit, FrequencyUnit: dates.Weekly, Goal: 3, CreatedAt: date(t, "2026-02-01")}
+	monthly := &models.Habit{ID: 3, Type: models.PositiveHabit, FrequencyUnit: dates.Monthly, Goal: 4, CreatedAt: date(t, "2026-02-01")}
+	later := &models.Habit{ID: 4, Type: models.PositiveHabit, FrequencyUnit: dates.Daily, CreatedAt: date(t, "2026-03-10")}
+
+	response := buildDashboard(user, day, &dashboardData{
+		habits: []*models.Habit{daily, weekly, monthly, later},
+		progress: map[int64]*models.HabitProgress{
+			1: {HabitID: 1, CompletedToday: true, StatusToday: models.TrackCompleted, WeekCount: 3, MonthCount: 3},
+			2: {HabitID: 2, WeekCount: 1, MonthCount: 5},
+			3: {HabitID: 3, MonthCount: 2},
+		},
+		completed: map[int64][]time.Time{
+			1: {date(t, "2026-03-02"), date(t, "2026-03-03"), date(t, "2026-03-04")},
+			2: {date(t, "2026-03-03")},
+			3: {date(t, "2026-03-01"), date(t, "2026-03-02")},
+		},
+	})
+
+	if len(response.Habits) != 3 {
+		t.Fatalf("got %d habits, want 3 without the one created later", len(response.Habits))
+	}
+
+	item := response.Habits[0]
+	if !item.Completed || item.Status != models.TrackCompleted || !item.Scheduled {
+		t.Errorf("daily habit = completed %v, status %q, scheduled %v; want completed and scheduled", item.Completed, item.Status, item.Scheduled)
+	}
+	if item.Streak.Length != 3 || item.StreakUnit != "days" {
+		t.Errorf("daily streak = %d %s, want 3 days", item.Streak.Length, item.StreakUnit)
+	}
+	if item.Progress.Period != dates.Daily || item.Progress.Count != 1 || item.Progress.Goal != 1 || !item.GoalReached {
+		t.Errorf("daily progress = %+v, want 1 of 1", item.Progress)
+	}
+
+	item = response.Habits[1]
+	if item.Progress.Period != dates.Weekly || item.Progress.Count != 1 || item.Progress.Goal != 3 || item.GoalReached {
+		t.Errorf("weekly progress = %+v, want 1 of 3", item.Progress)
+	}
+	if !item.Progress.StartDate.Equal(date(t, "2026-03-02")) || !item.Progress.EndDate.Equal(date(t, "2026-03-08")) {
+		t.Errorf("weekly progress covers %v to %v, want the week of 2026-03-02", item.Progress.StartDate, item.Progress.EndDate)
+	}
+
+	item = response.Habits[2]
+	if item.Progress.Period != dates.Monthly || item.Progress.Count != 2 || item.Progress.Goal != 4 {
+		t.Errorf("monthly progress = %+v, want 2 of 4", item.Progress)
+	}
+
+	// Every habit is due on the day; only the daily one was done
+	if response.Day.Completed != 1 || response.Day.Total != 3 {
+		t.Errorf("day = %d of %d, want 1 of 3", response.Day.Completed, response.Day.Total)
+	}
+
+	// Three elapsed days of the daily habit plus the weekly goal; the
+	// monthly habit has no weekly target
+	if response.Week.Completed != 4 || response.Week.Total != 6 {
+		t.Errorf("week = %d of %d, want 4 of 6", response.Week.Completed, response.Week.Total)
+	}
+	if want := completionRate(4, 6); response.Week.Rate != want {
+		t.Errorf("week rate = %v, want %v", response.Week.Rate, want)
+	}
+}
+
+func TestBuildDashboardEmpty(t *testing.T) {
+	user := &models.User{ID: 1, WeekStart: "monday"}
+
+	response := buildDashboard(user, date(t, "2026-03-04"), &dashboardData{})
+	if len(response.Habits) != 0 || response.Day.Total != 0 || response.Day.Rate != 0 || response.Week.Rate != 0 {
+		t.Errorf("empty dashboard = %+v, day %+v, week %+v", response.Habits, response.Day, response.Week)
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"gitlab.com/KARSTERRR/habitrack/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FreezeHandler handles streak freeze requests
+type FreezeHandler struct {
+	DB *sql.DB
+}
+
+// NewFreezeHandler creates a new streak freeze handler
+func NewFreezeHandler(db *sql.DB) *FreezeHandler {
+	return &FreezeHandler{DB: db}
+}
+
+// GetFreezes returns the current user's streak freeze balance and the ledger
+// of freezes earned, forfeited at the cap and spent, latest first
+func (h *FreezeHandler) GetFreezes(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	freezeRepo := models.NewFreezeRepository(h.DB)
+	summary, err := freezeRepo.GetSummary(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve streak freezes"})
+		return
+	}
+
+	history, err := freezeRepo.GetAllByUser(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve streak freezes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary": summary,
+		"history": history,
+	})
+}
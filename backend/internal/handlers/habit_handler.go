@@ -2,11 +2,17 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
+	"log"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
 	"gitlab.com/KARSTERRR/habitrack/models"
+	"gitlab.com/KARSTERRR/habitrack/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -22,6 +28,17 @@ func NewHabitHandler(db *sql.DB) *HabitHandler {
 	return &HabitHandler{DB: db}
 }
 
+// userLocation returns the time zone whose calendar days a user tracks
+// habits in, falling back to UTC
+func userLocation(db *sql.DB, userID int64) *time.Location {
+	userRepo := models.NewUserRepository(db)
+	loc, err := userRepo.GetLocation(userID)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
 // CreateHabit creates a new habit
 func (h *HabitHandler) CreateHabit(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
@@ -42,6 +59,14 @@ func (h *HabitHandler) CreateHabit(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := habit.ValidateSchedule(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := habit.ValidateDates(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Set user ID
 	habit.UserID = userID.(int64)
@@ -53,6 +78,7 @@ func (h *HabitHandler) CreateHabit(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", habitETag(&habit))
 	c.JSON(http.StatusCreated, habit)
 }
 
@@ -80,10 +106,12 @@ func (h *HabitHandler) GetHabit(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", habitETag(habit))
 	c.JSON(http.StatusOK, habit)
 }
 
-// UpdateHabit updates a habit
+// UpdateHabit replaces a habit with the request body. Fields left out are
+// reset; use PatchHabit to change only some.
 func (h *HabitHandler) UpdateHabit(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
 	userID, exists := c.Get("userID")
@@ -107,6 +135,11 @@ func (h *HabitHandler) UpdateHabit(c *gin.Context) {
 		return
 	}
 
+	if !matchesIfMatch(c, habit) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Habit was modified by another request"})
+		return
+	}
+
 	// Parse updated habit from request
 	var updatedHabit models.Habit
 	if err := c.ShouldBindJSON(&updatedHabit); err != nil {
@@ -120,27 +153,201 @@ func (h *HabitHandler) UpdateHabit(c *gin.Context) {
 		return
 	}
 
-	// Update fields
+	h.saveHabit(c, habit, &updatedHabit)
+}
+
+// PatchHabit changes the fields of a habit given in a JSON Merge Patch
+// (RFC 7396) body, leaving the others as they are. A null resets a field.
+func (h *HabitHandler) PatchHabit(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse habit ID from URL
+	habitID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/merge-patch+json"})
+		return
+	}
+
+	// Get current habit
+	habitRepo := models.NewHabitRepository(h.DB)
+	habit, err := habitRepo.GetByID(habitID, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+
+	if !matchesIfMatch(c, habit) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Habit was modified by another request"})
+		return
+	}
+
+	// Apply the patch to the habit as the API presents it
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	current, err := json.Marshal(habit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update habit"})
+		return
+	}
+	patched, err := utils.MergePatch(current, patch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var updatedHabit models.Habit
+	if err := json.Unmarshal(patched, &updatedHabit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	h.saveHabit(c, habit, &updatedHabit)
+}
+
+// saveHabit validates and stores the new state of a habit, provided the
+// habit is still at the version it was read at, and responds with it
+func (h *HabitHandler) saveHabit(c *gin.Context, habit *models.Habit, updatedHabit *models.Habit) {
+	habitID, userID := habit.ID, habit.UserID
+
+	// Fields the server manages
 	updatedHabit.ID = habitID
-	updatedHabit.UserID = userID.(int64)
+	updatedHabit.UserID = userID
 	updatedHabit.CreatedAt = habit.CreatedAt
+	updatedHabit.Version = habit.Version
 
 	validate := validator.New()
 	if err := validate.Struct(updatedHabit); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := updatedHabit.ValidateSchedule(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := updatedHabit.ValidateDates(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Update in database
-	if err := habitRepo.Update(&updatedHabit); err != nil {
+	// Update in database, unless another request changed the habit since
+	habitRepo := models.NewHabitRepository(h.DB)
+	if err := habitRepo.Update(updatedHabit); err != nil {
+		if err == models.ErrHabitVersionConflict {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Habit was modified by another request"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update habit"})
 		return
 	}
 
+	// A new type, target or aggregation mode changes how the events of each
+	// day combine into its tracking record
+	if updatedHabit.Type != habit.Type || updatedHabit.TargetValue != habit.TargetValue || updatedHabit.Aggregation != habit.Aggregation {
+		eventRepo := models.NewEventRepository(h.DB)
+		if err := eventRepo.RebuildAll(updatedHabit); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update habit"})
+			return
+		}
+	}
+
+	// Goal, frequency, schedule, target and dates all feed into the stored stats
+	jobRepo := models.NewStatsJobRepository(h.DB)
+	if err := jobRepo.Enqueue(habitID, userID); err != nil {
+		log.Printf("Failed to queue stats update for habit %d: %v", habitID, err)
+	}
+
+	c.Header("ETag", habitETag(updatedHabit))
 	c.JSON(http.StatusOK, updatedHabit)
 }
 
-// DeleteHabit deletes a habit
+// ArchiveHabit archives a habit, keeping its history. Archived habits are
+// purged once the retention period has passed.
+func (h *HabitHandler) ArchiveHabit(c *gin.Context) {
+	h.setArchived(c, true)
+}
+
+// UnarchiveHabit makes an archived habit active again
+func (h *HabitHandler) UnarchiveHabit(c *gin.Context) {
+	h.setArchived(c, false)
+}
+
+// setArchived archives or unarchives a habit and returns it
+func (h *HabitHandler) setArchived(c *gin.Context, archived bool) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse habit ID from URL
+	habitID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	// Check if habit belongs to user
+	habitRepo := models.NewHabitRepository(h.DB)
+	habit, err := habitRepo.GetByID(habitID, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+
+	if !matchesIfMatch(c, habit) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Habit was modified by another request"})
+		return
+	}
+
+	if archived {
+		err = habitRepo.Archive(habitID, userID.(int64), habit.Version)
+	} else {
+		err = habitRepo.Unarchive(habitID, userID.(int64), habit.Version)
+	}
+	if err != nil {
+		if err == models.ErrHabitVersionConflict {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Habit was modified by another request"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update habit"})
+		return
+	}
+
+	habit, err = habitRepo.GetByID(habitID, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve habit"})
+		return
+	}
+
+	// Archived habits miss the daily refresh, so their stats are stale
+	if !archived {
+		jobRepo := models.NewStatsJobRepository(h.DB)
+		if err := jobRepo.Enqueue(habitID, userID.(int64)); err != nil {
+			log.Printf("Failed to queue stats update for habit %d: %v", habitID, err)
+		}
+	}
+
+	c.Header("ETag", habitETag(habit))
+	c.JSON(http.StatusOK, habit)
+}
+
+// DeleteHabit permanently deletes a habit along with its check-ins, tracking
+// records and stats. Use ArchiveHabit to keep the history.
 func (h *HabitHandler) DeleteHabit(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
 	userID, exists := c.Get("userID")
@@ -156,9 +363,21 @@ func (h *HabitHandler) DeleteHabit(c *gin.Context) {
 		return
 	}
 
-	// Delete from database
+	// Check if habit belongs to user
 	habitRepo := models.NewHabitRepository(h.DB)
-	if err := habitRepo.Delete(habitID, userID.(int64)); err != nil {
+	habit, err := habitRepo.GetByID(habitID, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+
+	if !matchesIfMatch(c, habit) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Habit was modified by another request"})
+		return
+	}
+
+	// Delete from database
+	if err := habitRepo.Purge(habitID, userID.(int64)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete habit"})
 		return
 	}
@@ -192,7 +411,9 @@ func (h *HabitHandler) ListHabits(c *gin.Context) {
 	c.JSON(http.StatusOK, habits)
 }
 
-// TrackHabit tracks a habit for a specific date
+// TrackHabit records a check-in of a habit for a specific date and returns
+// the resulting record of that day. A check-in with status skipped excuses
+// the day, e.g. for illness, so that it is neither a success nor a miss.
 func (h *HabitHandler) TrackHabit(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
 	userID, exists := c.Get("userID")
@@ -210,45 +431,173 @@ func (h *HabitHandler) TrackHabit(c *gin.Context) {
 
 	// Check if habit belongs to user
 	habitRepo := models.NewHabitRepository(h.DB)
-	_, err = habitRepo.GetByID(habitID, userID.(int64))
+	habit, err := habitRepo.GetByID(habitID, userID.(int64))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
 		return
 	}
 
-	// Parse track record from request
-	var record models.HabitTrackRecord
-	if err := c.ShouldBindJSON(&record); err != nil {
+	if habit.IsArchived {
+		c.JSON(http.StatusConflict, gin.H{"error": "Archived habits can't be tracked"})
+		return
+	}
+
+	// Parse check-in from request
+	var event models.HabitEvent
+	if err := c.ShouldBindJSON(&event); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
 
-	// Set habit ID
-	record.HabitID = habitID
+	validate := validator.New()
+	if err := validate.Struct(event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// If date is not provided, use current date
-	if record.Date.IsZero() {
-		record.Date = time.Now().Truncate(24 * time.Hour)
+	// Set habit ID and time of the check-in
+	event.HabitID = habitID
+	event.OccurredAt = time.Now()
+
+	// If date is not provided, use the current date in the user's time zone.
+	// Days that haven't begun there yet can't be tracked.
+	today := dates.Today(userLocation(h.DB, userID.(int64)))
+	if event.Date.IsZero() {
+		event.Date = today
+	} else {
+		event.Date = dates.Of(event.Date)
+	}
+	if event.Date.After(today) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot track a date in the future"})
+		return
 	}
 
-	// Save tracking record
-	trackRepo := models.NewTrackRepository(h.DB)
-	if err := trackRepo.TrackHabit(&record); err != nil {
+	// Append the event and derive the day's record from all its events
+	eventRepo := models.NewEventRepository(h.DB)
+	record, err := eventRepo.Create(habit, &event)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to track habit"})
 		return
 	}
 
-	// Update stats
-	statRepo := models.NewStatRepository(h.DB)
-	_, err = statRepo.UpdateStats(habitID, userID.(int64))
-	if err != nil {
-		// Non-critical error, just log it
-		// log.Printf("Failed to update stats: %v", err)
+	// Queue a stats recalculation; the check-in itself is already saved
+	jobRepo := models.NewStatsJobRepository(h.DB)
+	if err := jobRepo.Enqueue(habitID, userID.(int64)); err != nil {
+		log.Printf("Failed to queue stats update for habit %d: %v", habitID, err)
 	}
 
 	c.JSON(http.StatusOK, record)
 }
 
+// ListHabitEvents retrieves the individual check-ins of a habit for a date
+// range
+func (h *HabitHandler) ListHabitEvents(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse habit ID from URL
+	habitID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	// Check if habit belongs to user
+	habitRepo := models.NewHabitRepository(h.DB)
+	_, err = habitRepo.GetByID(habitID, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+
+	// Parse date range from query parameters, defaulting to the last 30 days
+	today := dates.Today(userLocation(h.DB, userID.(int64)))
+	startDate := today.AddDate(0, 0, -30)
+	endDate := today
+
+	if startDateStr := c.Query("start_date"); startDateStr != "" {
+		startDate, err = dates.Parse(startDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date format (use YYYY-MM-DD)"})
+			return
+		}
+	}
+
+	if endDateStr := c.Query("end_date"); endDateStr != "" {
+		endDate, err = dates.Parse(endDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end date format (use YYYY-MM-DD)"})
+			return
+		}
+	}
+
+	// Get events
+	eventRepo := models.NewEventRepository(h.DB)
+	events, err := eventRepo.GetEvents(habitID, startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve check-ins"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// DeleteHabitEvent removes a single check-in and returns the resulting
+// record of its day, null if no check-ins are left on that day
+func (h *HabitHandler) DeleteHabitEvent(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse habit and event IDs from URL
+	habitID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	eventID, err := strconv.ParseInt(c.Param("eventId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	// Check if habit belongs to user
+	habitRepo := models.NewHabitRepository(h.DB)
+	habit, err := habitRepo.GetByID(habitID, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+
+	// Remove the event and derive the day's record from the remaining ones
+	eventRepo := models.NewEventRepository(h.DB)
+	record, err := eventRepo.Delete(habit, eventID)
+	if err != nil {
+		if err == models.ErrEventNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Check-in not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete check-in"})
+		return
+	}
+
+	// Queue a stats recalculation
+	jobRepo := models.NewStatsJobRepository(h.DB)
+	if err := jobRepo.Enqueue(habitID, userID.(int64)); err != nil {
+		log.Printf("Failed to queue stats update for habit %d: %v", habitID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"day": record})
+}
+
 // GetHabitTracking retrieves habit tracking records for a date range
 func (h *HabitHandler) GetHabitTracking(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
@@ -277,13 +626,15 @@ func (h *HabitHandler) GetHabitTracking(c *gin.Context) {
 	startDateStr := c.Query("start_date")
 	endDateStr := c.Query("end_date")
 
+	today := dates.Today(userLocation(h.DB, userID.(int64)))
+
 	var startDate, endDate time.Time
 	if startDateStr == "" {
 		// Default to 30 days ago
-		startDate = time.Now().AddDate(0, 0, -30).Truncate(24 * time.Hour)
+		startDate = today.AddDate(0, 0, -30)
 	} else {
 		var err error
-		startDate, err = time.Parse("2006-01-02", startDateStr)
+		startDate, err = dates.Parse(startDateStr)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date format (use YYYY-MM-DD)"})
 			return
@@ -292,10 +643,10 @@ func (h *HabitHandler) GetHabitTracking(c *gin.Context) {
 
 	if endDateStr == "" {
 		// Default to today
-		endDate = time.Now().Truncate(24 * time.Hour)
+		endDate = today
 	} else {
 		var err error
-		endDate, err = time.Parse("2006-01-02", endDateStr)
+		endDate, err = dates.Parse(endDateStr)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end date format (use YYYY-MM-DD)"})
 			return
@@ -339,19 +690,76 @@ func (h *HabitHandler) GetHabitStats(c *gin.Context) {
 
 	// Parse period from query parameter
 	period := c.DefaultQuery("period", "weekly")
-	if period != "daily" && period != "weekly" && period != "monthly" && period != "yearly" {
+	if !dates.IsValidPeriod(period) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period (use daily, weekly, monthly, or yearly)"})
 		return
 	}
 
-	// Get stats from database
-	statRepo := models.NewStatRepository(h.DB)
-	
-	// First try to get existing stats
-	stats, err := statRepo.GetStats(habitID, userID.(int64), period)
+	// Periods follow the user's calendar
+	userRepo := models.NewUserRepository(h.DB)
+	user, err := userRepo.GetByID(userID.(int64))
 	if err != nil {
-		// If stats don't exist, calculate them
-		stats, err = statRepo.UpdateStats(habitID, userID.(int64))
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	today := dates.Today(user.Location())
+
+	// Parse date range from query parameters. The window covers the whole
+	// periods containing start_date and end_date, defaulting to the current one.
+	startDate, endDate := today, today
+	startDateStr := c.Query("start_date")
+	endDateStr := c.Query("end_date")
+
+	if startDateStr != "" {
+		startDate, err = dates.Parse(startDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date format (use YYYY-MM-DD)"})
+			return
+		}
+		if endDateStr == "" {
+			endDate = startDate
+		}
+	}
+
+	if endDateStr != "" {
+		endDate, err = dates.Parse(endDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end date format (use YYYY-MM-DD)"})
+			return
+		}
+		if startDateStr == "" {
+			startDate = endDate
+		}
+	}
+
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "End date must not be before start date"})
+		return
+	}
+
+	startDate, _ = dates.PeriodBounds(period, startDate, user.WeekStart)
+	_, endDate = dates.PeriodBounds(period, endDate, user.WeekStart)
+
+	statRepo := models.NewStatRepository(h.DB)
+
+	// Only the current period's stats are stored. Use them if they were
+	// calculated today, since streaks move with the calendar, and no
+	// recalculation is still queued after a check-in; other windows are
+	// calculated on the fly.
+	var stats *models.Stat
+	currentStart, currentEnd := dates.PeriodBounds(period, today, user.WeekStart)
+	if startDate.Equal(currentStart) && endDate.Equal(currentEnd) {
+		queued, err := models.NewStatsJobRepository(h.DB).IsQueued(habitID)
+		if err == nil && !queued {
+			stats, err = statRepo.GetStats(habitID, userID.(int64), period, startDate, endDate)
+			if err != nil || !dates.In(stats.CalculatedAt, user.Location()).Equal(today) {
+				stats = nil
+			}
+		}
+	}
+
+	if stats == nil {
+		stats, err = statRepo.Calculate(habitID, userID.(int64), period, startDate, endDate)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
 			return
@@ -360,3 +768,30 @@ func (h *HabitHandler) GetHabitStats(c *gin.Context) {
 
 	c.JSON(http.StatusOK, stats)
 }
+
+// habitETag returns the entity tag of a habit's current version
+func habitETag(habit *models.Habit) string {
+	return `"` + strconv.Itoa(habit.Version) + `"`
+}
+
+// matchesIfMatch reports whether the request's If-Match headers, if any,
+// name the habit's current version. Each header holds "*", which any
+// existing habit matches, or a comma-separated list of entity tags. Tags
+// weakened by a proxy (W/"3") still match, since versions are never reused.
+func matchesIfMatch(c *gin.Context, habit *models.Habit) bool {
+	headers := c.Request.Header.Values("If-Match")
+	if len(headers) == 0 {
+		return true
+	}
+
+	etag := habitETag(habit)
+	for _, header := range headers {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+	"gitlab.com/KARSTERRR/habitrack/internal/streak"
+	"gitlab.com/KARSTERRR/habitrack/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// heatmapMaxLevel is the highest intensity bucket; 0 means no activity
+const heatmapMaxLevel = 4
+
+// HeatmapHandler handles contribution heatmap requests
+type HeatmapHandler struct {
+	DB *sql.DB
+}
+
+// NewHeatmapHandler creates a new heatmap handler
+func NewHeatmapHandler(db *sql.DB) *HeatmapHandler {
+	return &HeatmapHandler{DB: db}
+}
+
+// HabitHeatmapDay is one day of a habit's heatmap
+type HabitHeatmapDay struct {
+	Date      time.Time `json:"date"`
+	Completed bool      `json:"completed"`
+	Status    string    `json:"status"`    // Status tracked on the day; empty if none
+	Scheduled bool      `json:"scheduled"` // Due under the habit's schedule
+	Value     float64   `json:"value"`
+	Level     int       `json:"level"`
+}
+
+// HeatmapDay is one day of the heatmap across all habits
+type HeatmapDay struct {
+	Date      time.Time `json:"date"`
+	Completed int       `json:"completed"` // Habits completed on the day
+	Total     int       `json:"total"`     // Habits that existed and were due on the day
+	Level     int       `json:"level"`
+}
+
+// GetHabitHeatmap returns a habit's activity for every day of a year.
+// Intensity grows with the tracked value relative to the habit's target
+// or daily goal; a completed day always reaches the top level.
+func (h *HeatmapHandler) GetHabitHeatmap(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse habit ID from URL
+	habitID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	// Check if habit belongs to user
+	habitRepo := models.NewHabitRepository(h.DB)
+	habit, err := habitRepo.GetByID(habitID, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	user, err := userRepo.GetByID(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	loc := user.Location()
+
+	year, ok := parseHeatmapYear(c, loc)
+	if !ok {
+		return
+	}
+	startDate := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	endDate := startDate.AddDate(1, 0, -1)
+
+	// Get tracking records for the whole year in one query
+	trackRepo := models.NewTrackRepository(h.DB)
+	records, err := trackRepo.GetTracking(habitID, startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tracking records"})
+		return
+	}
+
+	byDate := make(map[time.Time]*models.HabitTrackRecord, len(records))
+	skipped := make([]time.Time, 0)
+	for _, record := range records {
+		byDate[dates.Of(record.Date)] = record
+		if record.Status == models.TrackSkipped {
+			skipped = append(skipped, record.Date)
+		}
+	}
+
+	// Values are measured against the target of quantitative habits or the
+	// daily goal; other habits count each check-in as a full day
+	target := 1.0
+	if habit.IsQuantitative() {
+		target = habit.TargetValue
+	} else if habit.FrequencyUnit == dates.Daily && habit.Goal > 1 {
+		target = float64(habit.Goal)
+	}
+
+	pauseRepo := models.NewPauseRepository(h.DB)
+	pauses, err := pauseRepo.GetByHabit(habitID, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pauses"})
+		return
+	}
+	rule := habit.Rule(user, pauses, skipped)
+
+	// A habit to break is completed on each elapsed day it is expected on
+	// without a relapse
+	today := dates.Today(loc)
+	tracked := make([]time.Time, 0, 1)
+	if len(records) > 0 {
+		tracked = append(tracked, records[0].Date)
+	}
+	firstDay := habit.FirstDay(loc, tracked)
+
+	days := make([]*HabitHeatmapDay, 0, dates.DaysBetween(startDate, endDate))
+	for day := startDate; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		item := &HabitHeatmapDay{Date: day, Scheduled: rule.IsScheduled(day)}
+		record, ok := byDate[day]
+		if ok {
+			item.Status = record.Status
+		}
+		if habit.IsNegative() {
+			if ok {
+				item.Value = record.Value
+			}
+			if !day.Before(firstDay) && !day.After(today) && rule.IsActive(day) && (!ok || record.Completed) {
+				item.Completed = true
+				item.Level = heatmapMaxLevel
+			}
+		} else if ok {
+			item.Completed = record.Completed
+			item.Value = record.Value
+
+			ratio := record.Value / target
+			if record.Completed && ratio < 1 {
+				ratio = 1
+			}
+			item.Level = heatmapLevel(ratio)
+		}
+		days = append(days, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"habit_id":  habit.ID,
+		"year":      year,
+		"max_level": heatmapMaxLevel,
+		"days":      days,
+	})
+}
+
+// GetHeatmap returns, for every day of a year, how many of the user's
+// habits were completed out of those that existed and were due on that day
+func (h *HeatmapHandler) GetHeatmap(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	user, err := userRepo.GetByID(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	loc := user.Location()
+
+	year, ok := parseHeatmapYear(c, loc)
+	if !ok {
+		return
+	}
+	startDate := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	endDate := startDate.AddDate(1, 0, -1)
+
+	habitRepo := models.NewHabitRepository(h.DB)
+	habits, err := habitRepo.GetAllByUser(userID.(int64), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve habits"})
+		return
+	}
+
+	heatmapRepo := models.NewHeatmapRepository(h.DB)
+	firstTracked, err := heatmapRepo.GetFirstTrackedDates(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tracking records"})
+		return
+	}
+
+	completions, err := heatmapRepo.GetDailyCompletions(userID.(int64), startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tracking records"})
+		return
+	}
+
+	relapses, err := heatmapRepo.GetRelapseDates(userID.(int64), startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tracking records"})
+		return
+	}
+
+	skipped, err := heatmapRepo.GetSkippedDates(userID.(int64), startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tracking records"})
+		return
+	}
+
+	pauseRepo := models.NewPauseRepository(h.DB)
+	pauses, err := pauseRepo.GetAllByUser(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pauses"})
+		return
+	}
+
+	completedByDate := make(map[time.Time]int, len(completions))
+	for _, completion := range completions {
+		completedByDate[dates.Of(completion.Date)] = completion.Completed
+	}
+
+	// A habit counts from its start date, or else from the day it was created
+	// or its earliest backfilled day, until its end date, on the days its
+	// schedule makes it due and it wasn't paused or skipped. Habits to break
+	// are completed on each elapsed day without a relapse.
+	today := dates.Today(loc)
+	firstDays := make([]time.Time, 0, len(habits))
+	rules := make([]streak.Rule, 0, len(habits))
+	for _, habit := range habits {
+		tracked := make([]time.Time, 0, 1)
+		if first, ok := firstTracked[habit.ID]; ok {
+			tracked = append(tracked, first)
+		}
+		firstDay := habit.FirstDay(loc, tracked)
+		rule := habit.Rule(user, pauses, skipped[habit.ID])
+		firstDays = append(firstDays, firstDay)
+		rules = append(rules, rule)
+
+		if habit.IsNegative() {
+			for _, day := range streak.CleanDays(rule, relapses[habit.ID], maxDate(firstDay, startDate), minDate(today, endDate)) {
+				if rule.IsScheduled(day) {
+					completedByDate[day]++
+				}
+			}
+		}
+	}
+
+	days := make([]*HeatmapDay, 0, dates.DaysBetween(startDate, endDate))
+	for day := startDate; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		item := &HeatmapDay{Date: day, Completed: completedByDate[day]}
+		for i, firstDay := range firstDays {
+			if !firstDay.After(day) && rules[i].IsScheduled(day) {
+				item.Total++
+			}
+		}
+
+		if item.Total > 0 {
+			item.Level = heatmapLevel(float64(item.Completed) / float64(item.Total))
+		}
+		days = append(days, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"year":      year,
+		"max_level": heatmapMaxLevel,
+		"days":      days,
+	})
+}
+
+// minDate returns the earlier of two dates
+func minDate(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// maxDate returns the later of two dates
+func maxDate(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// parseHeatmapYear reads the year query parameter, defaulting to the
+// current year in loc. It responds with an error and returns false if the
+// parameter is invalid.
+func parseHeatmapYear(c *gin.Context, loc *time.Location) (int, bool) {
+	yearStr := c.Query("year")
+	if yearStr == "" {
+		return dates.Today(loc).Year(), true
+	}
+
+	year, err := strconv.Atoi(yearStr)
+	if err != nil || year < 1970 || year > 9999 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+		return 0, false
+	}
+
+	return year, true
+}
+
+// heatmapLevel buckets a completion ratio into an intensity level from 0
+// (nothing) to heatmapMaxLevel (goal reached)
+func heatmapLevel(ratio float64) int {
+	switch {
+	case ratio <= 0:
+		return 0
+	case ratio >= 1:
+		return heatmapMaxLevel
+	default:
+		// Spread partial progress over the levels below the top one
+		level := int(ratio*float64(heatmapMaxLevel-1)) + 1
+		if level >= heatmapMaxLevel {
+			level = heatmapMaxLevel - 1
+		}
+		return level
+	}
+}
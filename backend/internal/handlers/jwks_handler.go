@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gitlab.com/KARSTERRR/habitrack/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publishes the public token signing keys
+type JWKSHandler struct {
+	Keys *utils.KeySet
+}
+
+// NewJWKSHandler creates a new JWKS handler
+func NewJWKSHandler(keys *utils.KeySet) *JWKSHandler {
+	return &JWKSHandler{Keys: keys}
+}
+
+// GetJWKS returns the key set other services use to verify our tokens
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": h.Keys.JWKS()})
+}
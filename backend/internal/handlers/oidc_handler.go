@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/config"
+	"gitlab.com/KARSTERRR/habitrack/internal/oidc"
+	"gitlab.com/KARSTERRR/habitrack/models"
+	"gitlab.com/KARSTERRR/habitrack/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// oidcStateTTL is how long a user has to complete sign-in at the provider
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCHandler handles OpenID Connect login requests
+type OIDCHandler struct {
+	DB        *sql.DB
+	Config    *config.Config
+	Providers map[string]*oidc.Provider
+	Users     *UserHandler
+}
+
+// NewOIDCHandler creates a new OpenID Connect handler
+func NewOIDCHandler(db *sql.DB, cfg *config.Config, providers map[string]*oidc.Provider, users *UserHandler) *OIDCHandler {
+	return &OIDCHandler{DB: db, Config: cfg, Providers: providers, Users: users}
+}
+
+// OIDCCallbackRequest is the request body for completing an OIDC login.
+// Code and State are the values the provider sent to the redirect URL.
+type OIDCCallbackRequest struct {
+	Code       string `json:"code" validate:"required"`
+	State      string `json:"state" validate:"required"`
+	DeviceName string `json:"device_name" validate:"max=100"`
+}
+
+// ListProviders lists the configured login providers
+func (h *OIDCHandler) ListProviders(c *gin.Context) {
+	names := make([]string, 0, len(h.Providers))
+	for name := range h.Providers {
+		names = append(names, name)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"providers": names})
+}
+
+// StartLogin begins the authorization code flow with PKCE and returns the
+// URL the client should open
+func (h *OIDCHandler) StartLogin(c *gin.Context) {
+	provider, ok := h.Providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	codeVerifier, err := utils.GenerateRandomToken(48)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(state, nonce, codeVerifier)
+	if err != nil {
+		log.Printf("OIDC provider %s unavailable: %v", provider.Config.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Login provider is unavailable"})
+		return
+	}
+
+	identityRepo := models.NewIdentityRepository(h.DB)
+	if err := identityRepo.SaveLoginState(&models.OIDCLoginState{
+		StateHash:    utils.HashToken(state),
+		Provider:     provider.Config.Name,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"authorization_url": authURL,
+		"state":             state,
+	})
+}
+
+// CompleteLogin exchanges the authorization code, validates the ID token and
+// signs the user in, linking or creating an account as needed
+func (h *OIDCHandler) CompleteLogin(c *gin.Context) {
+	provider, ok := h.Providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return
+	}
+
+	var req OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	identityRepo := models.NewIdentityRepository(h.DB)
+	state, err := identityRepo.ConsumeLoginState(utils.HashToken(req.State), provider.Config.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+
+	rawIDToken, err := provider.Exchange(req.Code, state.CodeVerifier)
+	if err != nil {
+		log.Printf("OIDC code exchange with %s failed: %v", provider.Config.Name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to complete login with provider"})
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(rawIDToken, state.Nonce)
+	if err != nil {
+		log.Printf("OIDC ID token from %s rejected: %v", provider.Config.Name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to complete login with provider"})
+		return
+	}
+
+	user, err := h.resolveUser(provider.Config.Name, claims)
+	if err != nil {
+		if e, ok := err.(oidcError); ok {
+			c.JSON(http.StatusConflict, gin.H{"error": e.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete login"})
+		return
+	}
+
+	h.Users.loginOrChallenge(c, user, req.DeviceName)
+}
+
+// resolveUser finds the user for an external identity. Unknown identities
+// are linked to an existing account only when both the provider and the
+// account owner have verified the email address; otherwise a new account is
+// created.
+func (h *OIDCHandler) resolveUser(provider string, claims *oidc.IDTokenClaims) (*models.User, error) {
+	identityRepo := models.NewIdentityRepository(h.DB)
+	userRepo := models.NewUserRepository(h.DB)
+
+	if identity, err := identityRepo.GetBySubject(provider, claims.Subject); err == nil {
+		if err := identityRepo.UpdateLastLogin(identity.ID); err != nil {
+			log.Printf("Failed to update identity last login: %v", err)
+		}
+		return userRepo.GetByID(identity.UserID)
+	}
+
+	if claims.Email == "" {
+		return nil, errMissingEmail
+	}
+
+	// Linking to an existing account needs both sides to have proven the
+	// address, or whoever registered it first would take over the identity
+	user, err := userRepo.GetByEmail(claims.Email)
+	switch {
+	case err == nil:
+		if !claims.EmailVerified || !user.IsEmailVerified() {
+			return nil, errUnverifiedEmail
+		}
+	case err == models.ErrUserNotFound:
+		user, err = h.createUser(claims)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if claims.EmailVerified {
+		if err := userRepo.MarkEmailVerified(user.ID); err != nil {
+			log.Printf("Failed to mark email verified: %v", err)
+		}
+	}
+
+	if err := identityRepo.Create(&models.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}); err != nil {
+		return nil, errLinkFailed
+	}
+
+	return userRepo.GetByID(user.ID)
+}
+
+// createUser registers a new account for an external identity. The account
+// gets a random password that can be replaced through a password reset.
+func (h *OIDCHandler) createUser(claims *oidc.IDTokenClaims) (*models.User, error) {
+	password, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, errLinkFailed
+	}
+
+	user := &models.User{
+		Username: oidcUsername(claims),
+		Email:    claims.Email,
+		Password: password,
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	if err := userRepo.Create(user); err != nil {
+		return nil, errLinkFailed
+	}
+
+	return user, nil
+}
+
+// oidcUsername picks a username from the ID token claims
+func oidcUsername(claims *oidc.IDTokenClaims) string {
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Name
+	}
+	if username == "" {
+		username = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+
+	runes := []rune(username)
+	if len(runes) > 50 {
+		runes = runes[:50]
+	}
+	for len(runes) < 3 {
+		runes = append(runes, '_')
+	}
+
+	return string(runes)
+}
+
+// OIDC account resolution errors, returned to the client as-is
+var (
+	errMissingEmail    = oidcError("Login provider did not share an email address")
+	errUnverifiedEmail = oidcError("An account with this email already exists; sign in with your password and verify your email address to link it")
+	errLinkFailed      = oidcError("Failed to link account")
+)
+
+// oidcError is an error whose message is safe to show to clients
+type oidcError string
+
+func (e oidcError) Error() string { return string(e) }
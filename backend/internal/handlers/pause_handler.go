@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+	"gitlab.com/KARSTERRR/habitrack/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// PauseHandler handles habit pause and vacation mode requests
+type PauseHandler struct {
+	DB *sql.DB
+}
+
+// NewPauseHandler creates a new pause handler
+func NewPauseHandler(db *sql.DB) *PauseHandler {
+	return &PauseHandler{DB: db}
+}
+
+// CreatePauseRequest is the request body for pausing a habit or every habit
+type CreatePauseRequest struct {
+	StartDate *dates.Date `json:"start_date"` // Defaults to today; can't be in the past
+	EndDate   *dates.Date `json:"end_date"`   // Omit for an open-ended pause
+	Reason    string      `json:"reason" validate:"max=200"`
+}
+
+// ListPauses lists every pause of the current user, per habit and
+// account-wide, latest first
+func (h *PauseHandler) ListPauses(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	pauseRepo := models.NewPauseRepository(h.DB)
+	pauses, err := pauseRepo.GetAllByUser(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pauses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pauses)
+}
+
+// CreatePause turns on vacation mode, pausing every habit of the current user
+func (h *PauseHandler) CreatePause(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	h.createPause(c, userID.(int64), nil)
+}
+
+// ListHabitPauses lists the pauses covering a habit, including account-wide
+// ones, latest first
+func (h *PauseHandler) ListHabitPauses(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse habit ID from URL
+	habitID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	// Check if habit belongs to user
+	habitRepo := models.NewHabitRepository(h.DB)
+	if _, err := habitRepo.GetByID(habitID, userID.(int64)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+
+	pauseRepo := models.NewPauseRepository(h.DB)
+	pauses, err := pauseRepo.GetByHabit(habitID, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pauses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pauses)
+}
+
+// CreateHabitPause pauses a single habit
+func (h *PauseHandler) CreateHabitPause(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse habit ID from URL
+	habitID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	// Check if habit belongs to user
+	habitRepo := models.NewHabitRepository(h.DB)
+	if _, err := habitRepo.GetByID(habitID, userID.(int64)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+
+	h.createPause(c, userID.(int64), &habitID)
+}
+
+// EndPause ends a pause early, so that today counts again. A pause that
+// hasn't begun yet is removed.
+func (h *PauseHandler) EndPause(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse pause ID from URL
+	pauseID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pause ID"})
+		return
+	}
+
+	pauseRepo := models.NewPauseRepository(h.DB)
+	pause, err := pauseRepo.GetByID(pauseID, userID.(int64))
+	if err != nil {
+		if err == models.ErrPauseNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pause not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pause"})
+		return
+	}
+
+	yesterday := dates.NewDate(dates.Today(userLocation(h.DB, userID.(int64))).AddDate(0, 0, -1))
+	if pause.EndDate != nil && !pause.EndDate.After(yesterday.Time) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pause has already ended"})
+		return
+	}
+
+	if yesterday.Before(pause.StartDate.Time) {
+		if err := pauseRepo.Delete(pauseID, userID.(int64)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end pause"})
+			return
+		}
+		h.queueStats(pause)
+		c.JSON(http.StatusOK, gin.H{"message": "Pause removed"})
+		return
+	}
+
+	if err := pauseRepo.SetEndDate(pauseID, userID.(int64), yesterday); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end pause"})
+		return
+	}
+	pause.EndDate = &yesterday
+	h.queueStats(pause)
+
+	c.JSON(http.StatusOK, pause)
+}
+
+// DeletePause removes a pause that hasn't begun yet. Pauses that have begun
+// can only be ended, so that the days they covered stay excused.
+func (h *PauseHandler) DeletePause(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse pause ID from URL
+	pauseID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pause ID"})
+		return
+	}
+
+	pauseRepo := models.NewPauseRepository(h.DB)
+	pause, err := pauseRepo.GetByID(pauseID, userID.(int64))
+	if err != nil {
+		if err == models.ErrPauseNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pause not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pause"})
+		return
+	}
+
+	today := dates.Today(userLocation(h.DB, userID.(int64)))
+	if !pause.StartDate.After(today) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pause has already begun; end it instead"})
+		return
+	}
+
+	if err := pauseRepo.Delete(pauseID, userID.(int64)); err != nil {
+		if err == models.ErrPauseNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pause not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete pause"})
+		return
+	}
+	h.queueStats(pause)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pause deleted successfully"})
+}
+
+// createPause binds, checks and stores a pause of a habit, or of every habit
+// when habitID is nil
+func (h *PauseHandler) createPause(c *gin.Context, userID int64, habitID *int64) {
+	var req CreatePauseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Pauses can't be backdated to excuse days already missed
+	today := dates.NewDate(dates.Today(userLocation(h.DB, userID)))
+	pause := &models.Pause{
+		UserID:    userID,
+		HabitID:   habitID,
+		StartDate: today,
+		EndDate:   req.EndDate,
+		Reason:    req.Reason,
+	}
+	if req.StartDate != nil {
+		if req.StartDate.Before(today.Time) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must not be in the past"})
+			return
+		}
+		pause.StartDate = *req.StartDate
+	}
+	if pause.EndDate != nil && pause.EndDate.Before(pause.StartDate.Time) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+		return
+	}
+
+	pauseRepo := models.NewPauseRepository(h.DB)
+	if err := pauseRepo.Create(pause); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pause"})
+		return
+	}
+	h.queueStats(pause)
+
+	c.JSON(http.StatusCreated, pause)
+}
+
+// queueStats queues a recalculation of the stats of the habits a pause covers
+func (h *PauseHandler) queueStats(pause *models.Pause) {
+	jobRepo := models.NewStatsJobRepository(h.DB)
+	if pause.HabitID != nil {
+		if err := jobRepo.Enqueue(*pause.HabitID, pause.UserID); err != nil {
+			log.Printf("Failed to queue stats update for habit %d: %v", *pause.HabitID, err)
+		}
+		return
+	}
+
+	if err := jobRepo.EnqueueUser(pause.UserID); err != nil {
+		log.Printf("Failed to queue stats update for user %d: %v", pause.UserID, err)
+	}
+}
@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/config"
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+	"gitlab.com/KARSTERRR/habitrack/internal/mailer"
+	"gitlab.com/KARSTERRR/habitrack/models"
+	"gitlab.com/KARSTERRR/habitrack/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ProfileHandler handles requests for managing the current user's account
+type ProfileHandler struct {
+	DB     *sql.DB
+	Config *config.Config
+	Users  *UserHandler // Shares mail delivery and the brute-force limiter
+}
+
+// NewProfileHandler creates a new profile handler
+func NewProfileHandler(db *sql.DB, cfg *config.Config, users *UserHandler) *ProfileHandler {
+	return &ProfileHandler{DB: db, Config: cfg, Users: users}
+}
+
+// UpdateProfileRequest is the request body for updating the profile.
+// Omitted fields are left unchanged.
+type UpdateProfileRequest struct {
+	Username  *string `json:"username" validate:"omitempty,min=3,max=50"`
+	Timezone  *string `json:"timezone"` // IANA time zone name
+	WeekStart *string `json:"week_start" validate:"omitempty,oneof=monday sunday saturday"`
+}
+
+// ChangePasswordRequest is the request body for changing the password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=6"`
+}
+
+// ChangeEmailRequest is the request body for changing the email address
+type ChangeEmailRequest struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required"`
+}
+
+// ConfirmEmailChangeRequest is the request body for confirming a new email address
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// DeleteAccountRequest is the request body for deleting the account
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// UpdateProfile updates the current user's profile
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Timezone != nil && !dates.IsValidTimezone(*req.Timezone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone (use an IANA name such as Europe/Berlin)"})
+		return
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	user, err := userRepo.GetByID(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if req.Username != nil {
+		if err := userRepo.UpdateUsername(user.ID, *req.Username); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+		user.Username = *req.Username
+	}
+
+	if req.Timezone != nil || req.WeekStart != nil {
+		if req.Timezone != nil {
+			user.Timezone = *req.Timezone
+		}
+		if req.WeekStart != nil {
+			user.WeekStart = *req.WeekStart
+		}
+		if err := userRepo.UpdateLocale(user.ID, user.Timezone, user.WeekStart); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+
+		// Day and week boundaries moved, so every stored window is stale
+		jobRepo := models.NewStatsJobRepository(h.DB)
+		if err := jobRepo.EnqueueUser(user.ID); err != nil {
+			log.Printf("Failed to queue stats update for user %d: %v", user.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, userProfile(user))
+}
+
+// ChangePassword changes the current user's password and signs out every
+// other session
+func (h *ProfileHandler) ChangePassword(c *gin.Context) {
+	// Get user and session IDs from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	sessionID, exists := c.Get("sessionID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	user, err := userRepo.GetByID(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !h.Users.confirmPassword(c, user, req.CurrentPassword) {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	if err := userRepo.UpdatePassword(user.ID, string(hashedPassword)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	// Sign out every session except this one and revoke API access
+	sessionRepo := models.NewSessionRepository(h.DB)
+	if err := sessionRepo.RevokeAllForUser(user.ID, sessionID.(int64)); err != nil {
+		log.Printf("Failed to revoke sessions after password change: %v", err)
+	}
+	tokenRepo := models.NewAccessTokenRepository(h.DB)
+	if err := tokenRepo.RevokeAllForUser(user.ID); err != nil {
+		log.Printf("Failed to revoke access tokens after password change: %v", err)
+	}
+
+	h.Users.sendMail(&mailer.Message{
+		To:      user.Email,
+		Subject: "Your HabiTrack password was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe password of your HabiTrack account was just changed, your other devices were signed out and your personal access tokens were revoked.\n\nIf this wasn't you, reset your password at %s/forgot-password.\n",
+			user.Username, h.Config.AppURL,
+		),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// ChangeEmail starts an email address change. The new address takes effect
+// once it is confirmed with the token mailed to it.
+func (h *ProfileHandler) ChangeEmail(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	user, err := userRepo.GetByID(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !h.Users.confirmPassword(c, user, req.Password) {
+		return
+	}
+
+	if strings.EqualFold(req.Email, user.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This is already your email address"})
+		return
+	}
+
+	// Check that the address isn't taken
+	if _, err := userRepo.GetByEmail(req.Email); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+		return
+	}
+
+	changeToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process email change"})
+		return
+	}
+	expires := time.Now().Add(emailVerificationTTL)
+
+	if err := userRepo.SetPendingEmail(user.ID, req.Email, utils.HashToken(changeToken), expires); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process email change"})
+		return
+	}
+
+	// Send the confirmation link to the new address and a notice to the old one
+	h.Users.sendMail(&mailer.Message{
+		To:      req.Email,
+		Subject: "Confirm your new HabiTrack email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your new email address using the link below. It expires in %d hours.\n\n%s/confirm-email-change?token=%s\n",
+			user.Username, int(emailVerificationTTL.Hours()), h.Config.AppURL, changeToken,
+		),
+	})
+	h.Users.sendMail(&mailer.Message{
+		To:      user.Email,
+		Subject: "Your HabiTrack email address is being changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nA change of your account's email address to %s was requested. It takes effect once the new address is confirmed.\n\nIf this wasn't you, change your password at %s.\n",
+			user.Username, req.Email, h.Config.AppURL,
+		),
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":       "Check your new email address for a confirmation link",
+		"pending_email": req.Email,
+	})
+}
+
+// ConfirmEmailChange switches an account to its new email address
+func (h *ProfileHandler) ConfirmEmailChange(c *gin.Context) {
+	var req ConfirmEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	if _, err := userRepo.ConfirmEmailChange(utils.HashToken(req.Token)); err != nil {
+		if err == models.ErrEmailTaken {
+			c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired email change token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address changed successfully"})
+}
+
+// DeleteAccount schedules the current user's account for deletion. The
+// account and all its data are purged once the grace period has passed,
+// unless the user cancels before then.
+func (h *ProfileHandler) DeleteAccount(c *gin.Context) {
+	// Get user and session IDs from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	sessionID, exists := c.Get("sessionID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	user, err := userRepo.GetByID(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !h.Users.confirmPassword(c, user, req.Password) {
+		return
+	}
+
+	if user.DeletionScheduledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Account deletion is already scheduled", "deletion_scheduled_at": user.DeletionScheduledAt})
+		return
+	}
+
+	deleteAt := time.Now().Add(h.Config.DeletionGrace)
+	if err := userRepo.ScheduleDeletion(user.ID, deleteAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	// Sign out every other device and revoke API access; this session stays
+	// so the user can cancel
+	sessionRepo := models.NewSessionRepository(h.DB)
+	if err := sessionRepo.RevokeAllForUser(user.ID, sessionID.(int64)); err != nil {
+		log.Printf("Failed to revoke sessions after account deletion: %v", err)
+	}
+	tokenRepo := models.NewAccessTokenRepository(h.DB)
+	if err := tokenRepo.RevokeAllForUser(user.ID); err != nil {
+		log.Printf("Failed to revoke access tokens after account deletion: %v", err)
+	}
+
+	h.Users.sendMail(&mailer.Message{
+		To:      user.Email,
+		Subject: "Your HabiTrack account will be deleted",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nYour HabiTrack account and all of its data will be permanently deleted on %s.\n\nChanged your mind? Sign in before then and cancel the deletion in your account settings.\n",
+			user.Username, deleteAt.UTC().Format("January 2, 2006 at 15:04 UTC"),
+		),
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":               "Account scheduled for deletion",
+		"deletion_scheduled_at": deleteAt,
+	})
+}
+
+// CancelDeletion cancels a scheduled deletion of the current user's account
+func (h *ProfileHandler) CancelDeletion(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	if err := userRepo.CancelDeletion(userID.(int64)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No account deletion is scheduled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
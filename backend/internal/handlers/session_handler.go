@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"gitlab.com/KARSTERRR/habitrack/config"
+	"gitlab.com/KARSTERRR/habitrack/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler handles session-related requests
+type SessionHandler struct {
+	DB     *sql.DB
+	Config *config.Config
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(db *sql.DB, cfg *config.Config) *SessionHandler {
+	return &SessionHandler{DB: db, Config: cfg}
+}
+
+// Logout ends the session of the current access token
+func (h *SessionHandler) Logout(c *gin.Context) {
+	// Get user and session IDs from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	sessionID, exists := c.Get("sessionID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessionRepo := models.NewSessionRepository(h.DB)
+	if err := sessionRepo.Revoke(sessionID.(int64), userID.(int64)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// ListSessions lists the active sessions of the current user
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	sessionID, _ := c.Get("sessionID")
+
+	sessionRepo := models.NewSessionRepository(h.DB)
+	sessions, err := sessionRepo.GetActiveByUser(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions"})
+		return
+	}
+
+	// Flag the session making this request
+	for _, session := range sessions {
+		if id, ok := sessionID.(int64); ok && session.ID == id {
+			session.Current = true
+		}
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession signs out one of the current user's sessions
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse session ID from URL
+	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	sessionRepo := models.NewSessionRepository(h.DB)
+	if err := sessionRepo.Revoke(sessionID, userID.(int64)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
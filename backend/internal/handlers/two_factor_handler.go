@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/config"
+	"gitlab.com/KARSTERRR/habitrack/internal/throttle"
+	"gitlab.com/KARSTERRR/habitrack/models"
+	"gitlab.com/KARSTERRR/habitrack/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// mfaChallengeTTL is how long a user has to enter their second factor
+	mfaChallengeTTL = 5 * time.Minute
+	// recoveryCodeCount is the number of recovery codes issued on enrollment
+	recoveryCodeCount = 10
+	// totpIssuer is the account issuer shown in authenticator apps
+	totpIssuer = "HabiTrack"
+)
+
+// TwoFactorHandler handles two-factor enrollment requests
+type TwoFactorHandler struct {
+	DB     *sql.DB
+	Config *config.Config
+	Users  *UserHandler // Shares the brute-force limiter
+}
+
+// NewTwoFactorHandler creates a new two-factor handler
+func NewTwoFactorHandler(db *sql.DB, cfg *config.Config, users *UserHandler) *TwoFactorHandler {
+	return &TwoFactorHandler{DB: db, Config: cfg, Users: users}
+}
+
+// TwoFactorCodeRequest is the request body carrying a TOTP code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// TwoFactorDisableRequest is the request body for disabling two-factor.
+// Code may be a TOTP code or a recovery code.
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// MFALoginRequest is the request body for the second login step.
+// Code may be a TOTP code or a recovery code.
+type MFALoginRequest struct {
+	MFAToken   string `json:"mfa_token" validate:"required"`
+	Code       string `json:"code" validate:"required"`
+	DeviceName string `json:"device_name" validate:"max=100"`
+}
+
+// MFAChallengeResponse is returned by login when a second factor is required
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"` // Token expiration time in seconds
+}
+
+// GetStatus reports whether two-factor authentication is enabled
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	totpRepo := models.NewTOTPRepository(h.DB)
+	enabled, err := totpRepo.IsEnabled(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve two-factor status"})
+		return
+	}
+
+	remaining := 0
+	if enabled {
+		remaining, err = totpRepo.CountRecoveryCodes(userID.(int64))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve two-factor status"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  enabled,
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// Setup starts two-factor enrollment and returns the secret and its
+// provisioning URI for rendering as a QR code
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	user, err := userRepo.GetByID(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	totpRepo := models.NewTOTPRepository(h.DB)
+	if err := totpRepo.SavePending(user.ID, secret); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": utils.TOTPProvisioningURI(secret, totpIssuer, user.Email),
+	})
+}
+
+// Confirm completes enrollment with a code from the authenticator app and
+// returns the recovery codes. They are shown only once.
+func (h *TwoFactorHandler) Confirm(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	totpRepo := models.NewTOTPRepository(h.DB)
+	settings, err := totpRepo.GetByUserID(userID.(int64))
+	if err != nil || settings.IsEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No pending two-factor enrollment"})
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(settings.Secret, req.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(utils.NormalizeRecoveryCode(code))
+	}
+
+	if err := totpRepo.Confirm(userID.(int64), step, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// Disable turns two-factor authentication off. The user has to confirm both
+// their password and a current second factor.
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req TwoFactorDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	user, err := userRepo.GetByID(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !h.Users.confirmPassword(c, user, req.Password) {
+		return
+	}
+
+	// Codes are short, so guesses are throttled like at login
+	accountKey := throttle.AccountKey(models.AuthActionMFA, strconv.FormatInt(user.ID, 10), throttle.AccountRule)
+	ipKey := throttle.IPKey(models.AuthActionMFA, c.ClientIP(), throttle.IPRule)
+	if wait := h.Users.Limiter.Check(accountKey, ipKey); wait > 0 {
+		h.Users.recordAuthFailure(c, models.AuthActionMFA, user.Email, &user.ID, "locked_out")
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	if err := verifySecondFactor(h.DB, user.ID, req.Code); err != nil {
+		h.Users.recordAuthFailure(c, models.AuthActionMFA, user.Email, &user.ID, "invalid_code")
+		h.Users.failAttempt(c, http.StatusUnauthorized, "Invalid code", accountKey, ipKey)
+		return
+	}
+
+	h.Users.Limiter.Succeed(accountKey)
+
+	totpRepo := models.NewTOTPRepository(h.DB)
+	if err := totpRepo.Delete(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, a recovery code
+// for a user with two-factor enabled. Used codes can't be presented again.
+func verifySecondFactor(db *sql.DB, userID int64, code string) error {
+	totpRepo := models.NewTOTPRepository(db)
+	settings, err := totpRepo.GetByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	if !settings.IsEnabled() {
+		return errors.New("two-factor authentication not enabled")
+	}
+
+	if step, ok := utils.ValidateTOTP(settings.Secret, code, time.Now()); ok {
+		return totpRepo.UseStep(userID, step)
+	}
+
+	return totpRepo.UseRecoveryCode(userID, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+}
@@ -2,10 +2,17 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"gitlab.com/KARSTERRR/habitrack/config"
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+	"gitlab.com/KARSTERRR/habitrack/internal/mailer"
+	"gitlab.com/KARSTERRR/habitrack/internal/throttle"
 	"gitlab.com/KARSTERRR/habitrack/models"
 	"gitlab.com/KARSTERRR/habitrack/utils"
 
@@ -14,28 +21,41 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// passwordResetTTL is how long a password reset token stays valid
+	passwordResetTTL = time.Hour
+	// emailVerificationTTL is how long an email verification token stays valid
+	emailVerificationTTL = 48 * time.Hour
+)
+
 // UserHandler handles user-related requests
 type UserHandler struct {
-	DB     *sql.DB
-	Config *config.Config
+	DB      *sql.DB
+	Config  *config.Config
+	Mailer  mailer.Mailer
+	Keys    *utils.KeySet
+	Limiter *throttle.Limiter
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(db *sql.DB, cfg *config.Config) *UserHandler {
-	return &UserHandler{DB: db, Config: cfg}
+func NewUserHandler(db *sql.DB, cfg *config.Config, m mailer.Mailer, keys *utils.KeySet, limiter *throttle.Limiter) *UserHandler {
+	return &UserHandler{DB: db, Config: cfg, Mailer: m, Keys: keys, Limiter: limiter}
 }
 
 // RegisterRequest is the request body for user registration
 type RegisterRequest struct {
-	Username string `json:"username" validate:"required,min=3,max=50"`
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Username   string `json:"username" validate:"required,min=3,max=50"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required,min=6"`
+	DeviceName string `json:"device_name" validate:"max=100"`
+	Timezone   string `json:"timezone"` // Optional IANA time zone, defaults to UTC
 }
 
 // LoginRequest is the request body for user login
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required"`
+	DeviceName string `json:"device_name" validate:"max=100"`
 }
 
 // PasswordResetRequest is the request body for requesting a password reset
@@ -49,14 +69,195 @@ type PasswordResetConfirmRequest struct {
 	Password string `json:"password" validate:"required,min=6"`
 }
 
+// EmailVerificationRequest is the request body for verifying an email address
+type EmailVerificationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ResendVerificationRequest is the request body for resending a verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
 // AuthResponse is the response body for auth operations
 type AuthResponse struct {
-	Token        string `json:"token"`
-	RefreshToken string `json:"refresh_token"`
-	UserID       int64  `json:"user_id"`
-	Username     string `json:"username"`
-	Email        string `json:"email"`
-	ExpiresIn    int    `json:"expires_in"` // Token expiration time in seconds
+	Token         string `json:"token"`
+	RefreshToken  string `json:"refresh_token"`
+	UserID        int64  `json:"user_id"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	ExpiresIn     int    `json:"expires_in"` // Token expiration time in seconds
+}
+
+// startSession records a new signed-in session for a user
+func (h *UserHandler) startSession(c *gin.Context, user *models.User, deviceName string) (*models.Session, error) {
+	familyID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	if deviceName == "" {
+		deviceName = "Unknown device"
+	}
+
+	session := &models.Session{
+		UserID:     user.ID,
+		FamilyID:   familyID,
+		DeviceName: deviceName,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+
+	sessionRepo := models.NewSessionRepository(h.DB)
+	if err := sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// issueTokens generates an access token and a stored refresh token for a
+// user session. The refresh token joins the session's token family.
+func (h *UserHandler) issueTokens(user *models.User, session *models.Session) (*AuthResponse, error) {
+	token, err := utils.GenerateJWT(user, session.ID, h.Keys, h.Config.JWTExpiration)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenRepo := models.NewRefreshTokenRepository(h.DB)
+	if err := tokenRepo.Create(&models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(refreshToken),
+		FamilyID:  session.FamilyID,
+		ExpiresAt: time.Now().Add(h.Config.RefreshDuration),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		Token:         token,
+		RefreshToken:  refreshToken,
+		UserID:        user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.IsEmailVerified(),
+		ExpiresIn:     int(h.Config.JWTExpiration.Seconds()),
+	}, nil
+}
+
+// revokeFamily ends the session that owns a refresh token family
+func (h *UserHandler) revokeFamily(familyID string) {
+	sessionRepo := models.NewSessionRepository(h.DB)
+	session, err := sessionRepo.GetByFamilyID(familyID)
+	if err == nil {
+		err = sessionRepo.Revoke(session.ID, session.UserID)
+	}
+	if err != nil {
+		// Fall back to revoking the tokens directly
+		tokenRepo := models.NewRefreshTokenRepository(h.DB)
+		if err := tokenRepo.RevokeFamily(familyID); err != nil {
+			log.Printf("Failed to revoke refresh token family: %v", err)
+		}
+	}
+}
+
+// sendMail delivers a message in the background and logs failures
+func (h *UserHandler) sendMail(msg *mailer.Message) {
+	go func() {
+		if err := h.Mailer.Send(msg); err != nil {
+			log.Printf("Failed to send email to %s: %v", msg.To, err)
+		}
+	}()
+}
+
+// sendVerificationEmail creates a new verification token for a user and
+// emails it to them
+func (h *UserHandler) sendVerificationEmail(user *models.User) error {
+	verificationToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	expires := time.Now().Add(emailVerificationTTL)
+	if err := userRepo.SetEmailVerificationToken(user.ID, utils.HashToken(verificationToken), expires); err != nil {
+		return err
+	}
+
+	h.sendMail(&mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your HabiTrack email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address using the link below. It expires in %d hours.\n\n%s/verify-email?token=%s\n",
+			user.Username, int(emailVerificationTTL.Hours()), h.Config.AppURL, verificationToken,
+		),
+	})
+
+	return nil
+}
+
+// failAttempt records a failed attempt against the given keys and responds
+// with the error, or with 429 if the failure triggered a lockout
+func (h *UserHandler) failAttempt(c *gin.Context, status int, message string, keys ...throttle.Key) {
+	if wait := h.Limiter.Fail(keys...); wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	c.JSON(status, gin.H{"error": message})
+}
+
+// confirmPassword re-checks the user's password before a sensitive change,
+// applying the same brute-force limits as login. It responds and returns
+// false when the password is wrong or the account is locked out.
+func (h *UserHandler) confirmPassword(c *gin.Context, user *models.User, password string) bool {
+	key := throttle.AccountKey(models.AuthActionReauth, strconv.FormatInt(user.ID, 10), throttle.AccountRule)
+	if wait := h.Limiter.Check(key); wait > 0 {
+		h.recordAuthFailure(c, models.AuthActionReauth, user.Email, &user.ID, "locked_out")
+		respondTooManyAttempts(c, wait)
+		return false
+	}
+
+	if err := user.CheckPassword(password); err != nil {
+		h.recordAuthFailure(c, models.AuthActionReauth, user.Email, &user.ID, "invalid_password")
+		h.failAttempt(c, http.StatusUnauthorized, "Invalid password", key)
+		return false
+	}
+
+	h.Limiter.Succeed(key)
+	return true
+}
+
+// recordAuthFailure writes a failed authentication attempt to the audit log
+func (h *UserHandler) recordAuthFailure(c *gin.Context, action, email string, userID *int64, reason string) {
+	failureRepo := models.NewAuthFailureRepository(h.DB)
+	err := failureRepo.Create(&models.AuthFailure{
+		UserID:    userID,
+		Action:    action,
+		Email:     email,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Reason:    reason,
+	})
+	if err != nil {
+		log.Printf("Failed to record auth failure: %v", err)
+	}
+}
+
+// respondTooManyAttempts tells the client how long it is locked out for
+func respondTooManyAttempts(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many attempts, please try again later",
+		"retry_after": seconds,
+	})
 }
 
 // RegisterUser handles user registration
@@ -73,6 +274,11 @@ func (h *UserHandler) RegisterUser(c *gin.Context) {
 		return
 	}
 
+	if req.Timezone != "" && !dates.IsValidTimezone(req.Timezone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone (use an IANA name such as Europe/Berlin)"})
+		return
+	}
+
 	// Create user repository
 	userRepo := models.NewUserRepository(h.DB)
 
@@ -88,6 +294,7 @@ func (h *UserHandler) RegisterUser(c *gin.Context) {
 		Username: req.Username,
 		Email:    req.Email,
 		Password: req.Password,
+		Timezone: req.Timezone,
 	}
 
 	// Save user to database
@@ -96,16 +303,21 @@ func (h *UserHandler) RegisterUser(c *gin.Context) {
 		return
 	}
 
-	// Generate tokens
-	token, err := utils.GenerateJWT(user, h.Config.JWTSecret, h.Config.JWTExpiration)
+	// Ask the user to confirm their email address
+	if err := h.sendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+	}
+
+	// Start a session and generate its tokens
+	session, err := h.startSession(c, user, req.DeviceName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user, h.Config.RefreshSecret, h.Config.RefreshDuration)
+	authResponse, err := h.issueTokens(user, session)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
@@ -116,14 +328,7 @@ func (h *UserHandler) RegisterUser(c *gin.Context) {
 	}
 
 	// Send response
-	c.JSON(http.StatusCreated, AuthResponse{
-		Token:        token,
-		RefreshToken: refreshToken,
-		UserID:       user.ID,
-		Username:     user.Username,
-		Email:        user.Email,
-		ExpiresIn:    int(h.Config.JWTExpiration.Seconds()),
-	})
+	c.JSON(http.StatusCreated, authResponse)
 }
 
 // LoginUser handles user login
@@ -140,50 +345,138 @@ func (h *UserHandler) LoginUser(c *gin.Context) {
 		return
 	}
 
+	// Refuse attempts while the account or the client is locked out
+	accountKey := throttle.AccountKey(models.AuthActionLogin, req.Email, throttle.AccountRule)
+	ipKey := throttle.IPKey(models.AuthActionLogin, c.ClientIP(), throttle.IPRule)
+	if wait := h.Limiter.Check(accountKey, ipKey); wait > 0 {
+		h.recordAuthFailure(c, models.AuthActionLogin, req.Email, nil, "locked_out")
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
 	// Create user repository
 	userRepo := models.NewUserRepository(h.DB)
 
 	// Get user by email
 	user, err := userRepo.GetByEmail(req.Email)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		h.recordAuthFailure(c, models.AuthActionLogin, req.Email, nil, "unknown_email")
+		h.failAttempt(c, http.StatusUnauthorized, "Invalid email or password", accountKey, ipKey)
 		return
 	}
 
 	// Check password
 	if err := user.CheckPassword(req.Password); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		h.recordAuthFailure(c, models.AuthActionLogin, req.Email, &user.ID, "invalid_password")
+		h.failAttempt(c, http.StatusUnauthorized, "Invalid email or password", accountKey, ipKey)
+		return
+	}
+
+	h.Limiter.Succeed(accountKey)
+	h.loginOrChallenge(c, user, req.DeviceName)
+}
+
+// VerifyMFALogin completes a login with a TOTP or recovery code
+func (h *UserHandler) VerifyMFALogin(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
 
-	// Generate tokens
-	token, err := utils.GenerateJWT(user, h.Config.JWTSecret, h.Config.JWTExpiration)
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate the challenge token
+	claims, err := utils.ValidateMFAToken(req.MFAToken, h.Keys)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	// Codes are short, so guesses are throttled like passwords
+	accountKey := throttle.AccountKey(models.AuthActionMFA, strconv.FormatInt(claims.UserID, 10), throttle.AccountRule)
+	ipKey := throttle.IPKey(models.AuthActionMFA, c.ClientIP(), throttle.IPRule)
+	if wait := h.Limiter.Check(accountKey, ipKey); wait > 0 {
+		h.recordAuthFailure(c, models.AuthActionMFA, "", &claims.UserID, "locked_out")
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	if err := verifySecondFactor(h.DB, claims.UserID, req.Code); err != nil {
+		h.recordAuthFailure(c, models.AuthActionMFA, "", &claims.UserID, "invalid_code")
+		h.failAttempt(c, http.StatusUnauthorized, "Invalid code", accountKey, ipKey)
+		return
+	}
+
+	h.Limiter.Succeed(accountKey)
+
+	userRepo := models.NewUserRepository(h.DB)
+	user, err := userRepo.GetByID(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	h.completeLogin(c, user, req.DeviceName)
+}
+
+// loginOrChallenge finishes a login whose first factor has been checked.
+// Users with two-factor enabled get a challenge instead of tokens.
+func (h *UserHandler) loginOrChallenge(c *gin.Context, user *models.User, deviceName string) {
+	totpRepo := models.NewTOTPRepository(h.DB)
+	mfaEnabled, err := totpRepo.IsEnabled(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor status"})
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user, h.Config.RefreshSecret, h.Config.RefreshDuration)
+	if mfaEnabled {
+		mfaToken, err := utils.GenerateMFAToken(user, h.Keys, mfaChallengeTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+			ExpiresIn:   int(mfaChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	h.completeLogin(c, user, deviceName)
+}
+
+// completeLogin starts a session for an authenticated user and responds
+// with its tokens
+func (h *UserHandler) completeLogin(c *gin.Context, user *models.User, deviceName string) {
+	// Start a session and generate its tokens
+	session, err := h.startSession(c, user, deviceName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	authResponse, err := h.issueTokens(user, session)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	// Update last login timestamp
+	userRepo := models.NewUserRepository(h.DB)
 	if err := userRepo.UpdateLastLogin(user.ID); err != nil {
 		// Non-critical error, just log it
 		// log.Printf("Failed to update last login: %v", err)
 	}
 
 	// Send response
-	c.JSON(http.StatusOK, AuthResponse{
-		Token:        token,
-		RefreshToken: refreshToken,
-		UserID:       user.ID,
-		Username:     user.Username,
-		Email:        user.Email,
-		ExpiresIn:    int(h.Config.JWTExpiration.Seconds()),
-	})
+	c.JSON(http.StatusOK, authResponse)
 }
 
 // RefreshToken refreshes a user's auth token
@@ -197,43 +490,63 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Validate the refresh token
-	claims, err := utils.ValidateJWT(req.RefreshToken, h.Config.RefreshSecret)
+	// Look up the stored refresh token
+	tokenRepo := models.NewRefreshTokenRepository(h.DB)
+	stored, err := tokenRepo.GetByHash(utils.HashToken(req.RefreshToken))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
 		return
 	}
 
+	if stored.IsRevoked() || stored.IsExpired() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	// A token that was already exchanged is being replayed, so the family
+	// is compromised and must be revoked as a whole
+	if stored.IsUsed() {
+		h.revokeFamily(stored.FamilyID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used"})
+		return
+	}
+
+	// Mark the token as used; losing this race also counts as reuse
+	if err := tokenRepo.MarkUsed(stored.ID); err != nil {
+		h.revokeFamily(stored.FamilyID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used"})
+		return
+	}
+
 	// Get user by ID
 	userRepo := models.NewUserRepository(h.DB)
-	user, err := userRepo.GetByID(claims.UserID)
+	user, err := userRepo.GetByID(stored.UserID)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
 	}
 
-	// Generate new tokens
-	token, err := utils.GenerateJWT(user, h.Config.JWTSecret, h.Config.JWTExpiration)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	// Get the session that owns the token family
+	sessionRepo := models.NewSessionRepository(h.DB)
+	session, err := sessionRepo.GetByFamilyID(stored.FamilyID)
+	if err != nil || session.IsRevoked() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user, h.Config.RefreshSecret, h.Config.RefreshDuration)
+	if err := sessionRepo.Touch(session.ID, c.ClientIP()); err != nil {
+		log.Printf("Failed to update session activity: %v", err)
+	}
+
+	// Generate new tokens in the same family
+	authResponse, err := h.issueTokens(user, session)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	// Send response
-	c.JSON(http.StatusOK, AuthResponse{
-		Token:        token,
-		RefreshToken: refreshToken,
-		UserID:       user.ID,
-		Username:     user.Username,
-		Email:        user.Email,
-		ExpiresIn:    int(h.Config.JWTExpiration.Seconds()),
-	})
+	c.JSON(http.StatusOK, authResponse)
 }
 
 // RequestPasswordReset handles password reset request
@@ -244,6 +557,24 @@ func (h *UserHandler) RequestPasswordReset(c *gin.Context) {
 		return
 	}
 
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Every request counts against the limits to prevent mailbox flooding,
+	// whether or not the address is registered so the lockout doesn't reveal
+	// which addresses exist
+	accountKey := throttle.AccountKey(models.AuthActionResetRequest, req.Email, throttle.ResetRequestRule)
+	ipKey := throttle.IPKey(models.AuthActionResetRequest, c.ClientIP(), throttle.IPRule)
+	if wait := h.Limiter.Check(accountKey, ipKey); wait > 0 {
+		h.recordAuthFailure(c, models.AuthActionResetRequest, req.Email, nil, "locked_out")
+		respondTooManyAttempts(c, wait)
+		return
+	}
+	h.Limiter.Fail(accountKey, ipKey)
+
 	// Create user repository
 	userRepo := models.NewUserRepository(h.DB)
 
@@ -255,24 +586,33 @@ func (h *UserHandler) RequestPasswordReset(c *gin.Context) {
 		return
 	}
 
-	// Generate a random token for password reset
-	// In a real app, use a proper random token generation
-	// This is just a placeholder
-	resetToken := "reset-token-" + time.Now().Format("20060102150405")
-	expires := time.Now().Add(24 * time.Hour)
+	// Generate a random single-use token; only its hash is stored
+	resetToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process reset request"})
+		return
+	}
+	expires := time.Now().Add(passwordResetTTL)
 
-	// Save the token to user record
-	if err := userRepo.UpdatePasswordResetToken(user.Email, resetToken, expires); err != nil {
+	// Save the token hash to user record
+	if err := userRepo.UpdatePasswordResetToken(user.Email, utils.HashToken(resetToken), expires); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process reset request"})
 		return
 	}
 
-	// In a real app, send an email with the reset link
-	// For this example, we'll just return the token in the response
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Password reset link sent",
-		"token":   resetToken, // Only for development, remove in production
-	})
+	// Email the reset link in the background so the response time doesn't
+	// reveal whether the address is registered
+	msg := &mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your HabiTrack password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to reset your password. It expires in %d minutes.\n\n%s/reset-password?token=%s\n\nIf you didn't request this, you can ignore this email.\n",
+			user.Username, int(passwordResetTTL.Minutes()), h.Config.AppURL, resetToken,
+		),
+	}
+	h.sendMail(msg)
+
+	c.JSON(http.StatusOK, gin.H{"message": "If your email is registered, you will receive a password reset link"})
 }
 
 // ResetPassword handles password reset confirmation
@@ -289,16 +629,17 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	// Create user repository
-	userRepo := models.NewUserRepository(h.DB)
-
-	// Get user by reset token
-	user, err := userRepo.GetByResetToken(req.Token)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+	// Refuse attempts while the client is locked out after guessing tokens
+	ipKey := throttle.IPKey(models.AuthActionResetPassword, c.ClientIP(), throttle.IPRule)
+	if wait := h.Limiter.Check(ipKey); wait > 0 {
+		h.recordAuthFailure(c, models.AuthActionResetPassword, "", nil, "locked_out")
+		respondTooManyAttempts(c, wait)
 		return
 	}
 
+	// Create user repository
+	userRepo := models.NewUserRepository(h.DB)
+
 	// Hash the new password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -306,21 +647,94 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	// Update user's password
-	if err := userRepo.UpdatePassword(user.ID, string(hashedPassword)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+	// Update the password and consume the reset token
+	userID, err := userRepo.ResetPasswordWithToken(utils.HashToken(req.Token), string(hashedPassword))
+	if err != nil {
+		h.recordAuthFailure(c, models.AuthActionResetPassword, "", nil, "invalid_token")
+		h.failAttempt(c, http.StatusBadRequest, "Invalid or expired reset token", ipKey)
 		return
 	}
 
-	// Clear reset token
-	if err := userRepo.UpdatePasswordResetToken(user.Email, "", time.Time{}); err != nil {
-		// Non-critical error, just log it
-		// log.Printf("Failed to clear reset token: %v", err)
+	// Sign out every existing session and revoke API access
+	sessionRepo := models.NewSessionRepository(h.DB)
+	if err := sessionRepo.RevokeAllForUser(userID, 0); err != nil {
+		log.Printf("Failed to revoke sessions after password reset: %v", err)
+	}
+	tokenRepo := models.NewAccessTokenRepository(h.DB)
+	if err := tokenRepo.RevokeAllForUser(userID); err != nil {
+		log.Printf("Failed to revoke access tokens after password reset: %v", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset successfully"})
 }
 
+// VerifyEmail confirms a user's email address with a verification token
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var req EmailVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userRepo := models.NewUserRepository(h.DB)
+	if _, err := userRepo.VerifyEmailWithToken(utils.HashToken(req.Token)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address verified successfully"})
+}
+
+// ResendVerification sends a new verification email
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Throttled like reset requests so the endpoint can't be used to flood
+	// a mailbox, counting every request whether or not the address exists
+	accountKey := throttle.AccountKey(models.AuthActionResendVerification, req.Email, throttle.ResetRequestRule)
+	ipKey := throttle.IPKey(models.AuthActionResendVerification, c.ClientIP(), throttle.IPRule)
+	if wait := h.Limiter.Check(accountKey, ipKey); wait > 0 {
+		h.recordAuthFailure(c, models.AuthActionResendVerification, req.Email, nil, "locked_out")
+		respondTooManyAttempts(c, wait)
+		return
+	}
+	h.Limiter.Fail(accountKey, ipKey)
+
+	// Don't reveal whether the email exists or is already verified
+	response := gin.H{"message": "If your email is registered and unverified, you will receive a verification link"}
+
+	userRepo := models.NewUserRepository(h.DB)
+	user, err := userRepo.GetByEmail(req.Email)
+	if err != nil || user.IsEmailVerified() {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// A failure is only logged; an error response would reveal that the
+	// address belongs to an unverified account
+	if err := h.sendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 // GetCurrentUser retrieves the current user's profile
 func (h *UserHandler) GetCurrentUser(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
@@ -341,11 +755,21 @@ func (h *UserHandler) GetCurrentUser(c *gin.Context) {
 	}
 
 	// Return user profile without sensitive information
-	c.JSON(http.StatusOK, gin.H{
-		"id":        user.ID,
-		"username":  user.Username,
-		"email":     user.Email,
-		"created_at": user.CreatedAt,
-		"last_login": user.LastLogin,
-	})
+	c.JSON(http.StatusOK, userProfile(user))
+}
+
+// userProfile returns the fields of a user that are shown to themselves
+func userProfile(user *models.User) gin.H {
+	return gin.H{
+		"id":                    user.ID,
+		"username":              user.Username,
+		"email":                 user.Email,
+		"pending_email":         user.PendingEmail,
+		"created_at":            user.CreatedAt,
+		"last_login":            user.LastLogin,
+		"email_verified_at":     user.EmailVerifiedAt,
+		"deletion_scheduled_at": user.DeletionScheduledAt,
+		"timezone":              user.Timezone,
+		"week_start":            user.WeekStart,
+	}
 }
@@ -0,0 +1,113 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// New creates the mailer selected by the configuration
+func New(cfg *config.Config) Mailer {
+	switch cfg.MailDriver {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}
+	default:
+		return &LogMailer{Path: cfg.MailLogPath, From: cfg.MailFrom}
+	}
+}
+
+// SMTPMailer sends messages through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers a message over SMTP
+func (m *SMTPMailer) Send(msg *Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%s", m.Host, m.Port)
+	return smtp.SendMail(addr, auth, envelopeAddress(m.From), []string{msg.To}, formatMessage(m.From, msg))
+}
+
+// LogMailer writes messages to a file or the server log instead of sending
+// them. It is meant for local development.
+type LogMailer struct {
+	Path string
+	From string
+	mu   sync.Mutex
+}
+
+// Send writes a message to the configured file, or to the log if none is set
+func (m *LogMailer) Send(msg *Message) error {
+	data := formatMessage(m.From, msg)
+
+	if m.Path == "" {
+		log.Printf("Mail to %s:\n%s", msg.To, data)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, []byte("\r\n")...))
+	return err
+}
+
+// formatMessage renders the headers and body of a message
+func formatMessage(from string, msg *Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// envelopeAddress extracts the bare address from "Name <addr>"
+func envelopeAddress(from string) string {
+	if i := strings.LastIndex(from, "<"); i >= 0 {
+		return strings.TrimSuffix(from[i+1:], ">")
+	}
+	return from
+}
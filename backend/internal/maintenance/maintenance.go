@@ -0,0 +1,48 @@
+package maintenance
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/config"
+	"gitlab.com/KARSTERRR/habitrack/models"
+)
+
+// interval is how often the maintenance tasks run
+const interval = time.Hour
+
+// Start runs the periodic maintenance tasks in the background. Every task
+// is safe to run on several instances at once.
+func Start(db *sql.DB, cfg *config.Config) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			runTasks(db, cfg)
+			<-ticker.C
+		}
+	}()
+}
+
+// runTasks runs each maintenance task once, logging failures
+func runTasks(db *sql.DB, cfg *config.Config) {
+	userRepo := models.NewUserRepository(db)
+	deleted, err := userRepo.DeleteScheduled(time.Now())
+	if err != nil {
+		log.Printf("Failed to purge deleted accounts: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Purged %d deleted accounts", deleted)
+	}
+
+	if cfg.ArchiveRetention > 0 {
+		habitRepo := models.NewHabitRepository(db)
+		purged, err := habitRepo.PurgeArchived(time.Now().Add(-cfg.ArchiveRetention))
+		if err != nil {
+			log.Printf("Failed to purge archived habits: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d archived habits", purged)
+		}
+	}
+}
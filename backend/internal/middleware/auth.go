@@ -1,17 +1,25 @@
 package middleware
 
 import (
+	"database/sql"
+	"log"
 	"net/http"
 	"strings"
 
 	"gitlab.com/KARSTERRR/habitrack/config"
+	"gitlab.com/KARSTERRR/habitrack/models"
 	"gitlab.com/KARSTERRR/habitrack/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware is a middleware for authenticating JWT tokens
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// AuthMiddleware is a middleware for authenticating JWT tokens and personal
+// access tokens. JWTs whose session has been revoked are rejected before
+// they expire.
+func AuthMiddleware(db *sql.DB, keys *utils.KeySet) gin.HandlerFunc {
+	sessionRepo := models.NewSessionRepository(db)
+	tokenRepo := models.NewAccessTokenRepository(db)
+
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -31,16 +39,131 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 		// Get the token from the header
 		tokenString := parts[1]
 
+		// Personal access tokens are looked up instead of verified
+		if strings.HasPrefix(tokenString, models.PersonalAccessTokenPrefix) {
+			token, err := tokenRepo.GetByHash(utils.HashToken(tokenString))
+			if err != nil || !token.IsActive() {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				c.Abort()
+				return
+			}
+
+			if err := tokenRepo.Touch(token.ID); err != nil {
+				log.Printf("Failed to update access token activity: %v", err)
+			}
+
+			// Set user ID and granted scopes in context
+			c.Set("userID", token.UserID)
+			c.Set("tokenScopes", token.Scopes)
+			c.Next()
+			return
+		}
+
 		// Validate the token
-		claims, err := utils.ValidateJWT(tokenString, cfg.JWTSecret)
+		claims, err := utils.ValidateJWT(tokenString, keys)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		// Set user ID in context for handlers to use
+		// Check that the session is still active
+		session, err := sessionRepo.GetByID(claims.SessionID)
+		if err != nil || session.IsRevoked() || session.UserID != claims.UserID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
+		if err := sessionRepo.Touch(session.ID, c.ClientIP()); err != nil {
+			log.Printf("Failed to update session activity: %v", err)
+		}
+
+		// Set user and session IDs in context for handlers to use
 		c.Set("userID", claims.UserID)
+		c.Set("sessionID", claims.SessionID)
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// RequireScope rejects personal access tokens that lack a scope. Session
+// tokens carry every scope. It must run after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, isToken := c.Get("tokenScopes")
+		if !isToken {
+			c.Next()
+			return
+		}
+
+		for _, s := range scopes.([]string) {
+			if s == scope {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Token is missing the " + scope + " scope"})
+		c.Abort()
+	}
+}
+
+// RequireSession rejects personal access tokens, limiting a route to users
+// who signed in interactively. It must run after AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("sessionID"); !exists {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint requires a signed-in session"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail restricts users whose email address is not verified.
+// In "block" mode they are denied entirely; in "limit" mode they may only
+// read. It must run after AuthMiddleware.
+func RequireVerifiedEmail(db *sql.DB, cfg *config.Config) gin.HandlerFunc {
+	userRepo := models.NewUserRepository(db)
+
+	return func(c *gin.Context) {
+		if cfg.EmailVerification != "block" && cfg.EmailVerification != "limit" {
+			c.Next()
+			return
+		}
+
+		if cfg.EmailVerification == "limit" && isReadOnlyMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		verified, err := userRepo.IsEmailVerified(userID.(int64))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		if !verified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address has not been verified"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// isReadOnlyMethod reports whether an HTTP method doesn't modify data
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
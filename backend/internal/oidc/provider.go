@@ -0,0 +1,308 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// httpTimeout bounds every request made to an identity provider
+const httpTimeout = 10 * time.Second
+
+// discoveryTTL is how long discovery documents and key sets are cached
+const discoveryTTL = time.Hour
+
+// Discovery is the subset of the OpenID Provider metadata that we use
+type Discovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// IDTokenClaims holds the verified identity from an ID token
+type IDTokenClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// Provider performs the authorization code flow against one issuer
+type Provider struct {
+	Config *config.OIDCProvider
+	client *http.Client
+
+	mu          sync.Mutex
+	discovery   *Discovery
+	keys        *keySet
+	refreshedAt time.Time
+}
+
+// NewProvider creates a provider client. Discovery happens lazily on first use.
+func NewProvider(cfg *config.OIDCProvider) *Provider {
+	return &Provider{
+		Config: cfg,
+		client: &http.Client{Timeout: httpTimeout},
+	}
+}
+
+// NewProviders creates a client for every configured provider
+func NewProviders(cfg *config.Config) map[string]*Provider {
+	providers := make(map[string]*Provider, len(cfg.OIDCProviders))
+	for name, providerCfg := range cfg.OIDCProviders {
+		providers[name] = NewProvider(providerCfg)
+	}
+	return providers
+}
+
+// PKCEChallenge derives the S256 code challenge for a code verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL builds the URL the user is sent to in order to sign in
+func (p *Provider) AuthCodeURL(state, nonce, codeVerifier string) (string, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.Config.ClientID)
+	params.Set("redirect_uri", p.Config.RedirectURL)
+	params.Set("scope", strings.Join(p.Config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", PKCEChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return d.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for tokens and returns the raw ID token
+func (p *Provider) Exchange(code, codeVerifier string) (string, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	useBasicAuth := p.Config.ClientSecret != "" && supportsOnly(d.TokenAuthMethods, "client_secret_basic", "client_secret_post")
+	if !useBasicAuth {
+		form.Set("client_id", p.Config.ClientID)
+		if p.Config.ClientSecret != "" {
+			form.Set("client_secret", p.Config.ClientSecret)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if useBasicAuth {
+		req.SetBasicAuth(url.QueryEscape(p.Config.ClientID), url.QueryEscape(p.Config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("could not decode token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+
+	if body.IDToken == "" {
+		return "", errors.New("token response did not include an id_token")
+	}
+
+	return body.IDToken, nil
+}
+
+// VerifyIDToken checks the signature of an ID token against the provider's
+// JWKS and validates its issuer, audience, expiry and nonce
+func (p *Provider) VerifyIDToken(rawIDToken, nonce string) (*IDTokenClaims, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.Config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	claims := jwt.MapClaims{}
+
+	token, err := parser.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.getKey(kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid id_token")
+	}
+
+	if azp, ok := claims["azp"].(string); ok && azp != p.Config.ClientID {
+		return nil, errors.New("id_token authorized party mismatch")
+	}
+
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	result := &IDTokenClaims{}
+	result.Subject, _ = claims["sub"].(string)
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+	result.PreferredUsername, _ = claims["preferred_username"].(string)
+
+	// Some providers send email_verified as a string
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = v
+	case string:
+		result.EmailVerified = v == "true"
+	}
+
+	if result.Subject == "" {
+		return nil, errors.New("id_token has no subject")
+	}
+
+	return result, nil
+}
+
+// getDiscovery returns the cached discovery document, fetching it if needed
+func (p *Provider) getDiscovery() (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil && time.Since(p.refreshedAt) < discoveryTTL {
+		return p.discovery, nil
+	}
+
+	var d Discovery
+	if err := p.getJSON(p.Config.IssuerURL+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+
+	if strings.TrimSuffix(d.Issuer, "/") != p.Config.IssuerURL {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", d.Issuer, p.Config.IssuerURL)
+	}
+
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing required endpoints")
+	}
+
+	p.discovery = &d
+	p.keys = nil
+	p.refreshedAt = time.Now()
+	return p.discovery, nil
+}
+
+// getKey returns the verification key with the given ID. The key set is
+// refetched once when the ID is unknown, to pick up provider key rotation.
+func (p *Provider) getKey(kid string) (interface{}, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil {
+		if key, ok := p.keys.find(kid); ok {
+			return key, nil
+		}
+	}
+
+	var set jsonWebKeySet
+	if err := p.getJSON(d.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("could not fetch JWKS: %w", err)
+	}
+
+	p.keys = set.parse()
+	if key, ok := p.keys.find(kid); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("no signing key found for kid %q", kid)
+}
+
+// getJSON fetches a URL and decodes its JSON body
+func (p *Provider) getJSON(rawURL string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", rawURL, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// supportsOnly reports whether methods lists want but not other
+func supportsOnly(methods []string, want, other string) bool {
+	hasWant, hasOther := false, false
+	for _, m := range methods {
+		if m == want {
+			hasWant = true
+		}
+		if m == other {
+			hasOther = true
+		}
+	}
+	return hasWant && !hasOther
+}
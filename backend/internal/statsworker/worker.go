@@ -0,0 +1,111 @@
+package statsworker
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/models"
+)
+
+const (
+	// pollInterval is how often the queue is checked for due jobs
+	pollInterval = 2 * time.Second
+	// refreshInterval is how often users are checked for a new local day
+	refreshInterval = 5 * time.Minute
+	// lease is how long a claimed job is hidden from other workers. A job
+	// whose worker dies is picked up again once its lease runs out.
+	lease = 2 * time.Minute
+	// batchSize is the number of jobs claimed at once
+	batchSize = 20
+	// retryBase and retryMax bound the exponential backoff between attempts
+	retryBase = 10 * time.Second
+	retryMax  = time.Hour
+	// maxAttempts is the number of failures after which a job is set aside
+	maxAttempts = 10
+)
+
+// Start processes the statistics job queue in the background and queues a
+// refresh of every habit once a new day begins in its owner's time zone.
+// Any number of instances can run the worker at once.
+func Start(db *sql.DB) {
+	jobRepo := models.NewStatsJobRepository(db)
+	statRepo := models.NewStatRepository(db)
+
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			queued, err := jobRepo.EnqueueDailyRefresh(time.Now())
+			if err != nil {
+				log.Printf("Failed to queue daily stats refresh: %v", err)
+			} else if queued > 0 {
+				log.Printf("Queued daily stats refresh of %d habits", queued)
+			}
+			<-ticker.C
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			// Keep draining while full batches come back
+			for {
+				if runBatch(jobRepo, statRepo) < batchSize {
+					break
+				}
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// runBatch claims due jobs and recalculates their statistics, scheduling a
+// retry for each failure until a job runs out of attempts. Returns the
+// number of jobs claimed.
+func runBatch(jobRepo *models.StatsJobRepository, statRepo *models.StatRepository) int {
+	jobs, err := jobRepo.Claim(time.Now(), lease, batchSize)
+	if err != nil {
+		log.Printf("Failed to claim stats jobs: %v", err)
+		return 0
+	}
+
+	for _, job := range jobs {
+		if err := statRepo.UpdateStats(job.HabitID, job.UserID); err != nil {
+			log.Printf("Failed to update stats of habit %d (attempt %d): %v", job.HabitID, job.Attempts+1, err)
+			if job.Attempts+1 >= maxAttempts {
+				log.Printf("Giving up on stats job of habit %d after %d attempts", job.HabitID, job.Attempts+1)
+				if err := jobRepo.Fail(job, err.Error()); err != nil {
+					log.Printf("Failed to set aside stats job of habit %d: %v", job.HabitID, err)
+				}
+				continue
+			}
+			if err := jobRepo.Retry(job, time.Now().Add(backoff(job.Attempts)), err.Error()); err != nil {
+				log.Printf("Failed to reschedule stats job of habit %d: %v", job.HabitID, err)
+			}
+			continue
+		}
+
+		if err := jobRepo.Complete(job); err != nil {
+			log.Printf("Failed to complete stats job of habit %d: %v", job.HabitID, err)
+		}
+	}
+
+	return len(jobs)
+}
+
+// backoff returns the delay before retrying a job that has already failed
+// the given number of times
+func backoff(attempts int) time.Duration {
+	delay := retryBase
+	for i := 0; i < attempts && delay < retryMax; i++ {
+		delay *= 2
+	}
+	if delay > retryMax {
+		delay = retryMax
+	}
+	return delay
+}
@@ -0,0 +1,234 @@
+package streak
+
+import (
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+)
+
+// Schedules a habit can follow
+const (
+	// ScheduleFrequency expects Goal completions per FrequencyUnit period
+	ScheduleFrequency = "frequency"
+	// ScheduleWeekdays expects a completion on each listed weekday
+	ScheduleWeekdays = "weekdays"
+	// ScheduleInterval expects a completion every Interval days from Anchor
+	ScheduleInterval = "interval"
+	// ScheduleMonthDays expects a completion on each listed day of the month
+	ScheduleMonthDays = "monthdays"
+)
+
+// IsDayBased reports whether the rule schedules specific days rather than a
+// number of completions per period. On day-based schedules only scheduled
+// days can be missed.
+func (r Rule) IsDayBased() bool {
+	return r.Schedule == ScheduleWeekdays || r.Schedule == ScheduleInterval || r.Schedule == ScheduleMonthDays
+}
+
+// IsActive reports whether the habit is expected at all on date: within
+// its start and end dates, not paused and not skipped
+func (r Rule) IsActive(date time.Time) bool {
+	date = dates.Of(date)
+
+	if r.Skipped[date] {
+		return false
+	}
+
+	if !r.Start.IsZero() && date.Before(dates.Of(r.Start)) {
+		return false
+	}
+	if !r.End.IsZero() && date.After(dates.Of(r.End)) {
+		return false
+	}
+	for _, pause := range r.Pauses {
+		if !date.Before(dates.Of(pause.Start)) && (pause.End.IsZero() || !date.After(dates.Of(pause.End))) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsScheduled reports whether the habit is due on date. Every active day is
+// due under a frequency schedule.
+func (r Rule) IsScheduled(date time.Time) bool {
+	date = dates.Of(date)
+	if !r.IsActive(date) {
+		return false
+	}
+
+	switch r.Schedule {
+	case ScheduleWeekdays:
+		return containsDay(r.Days, isoWeekday(date))
+	case ScheduleInterval:
+		if r.Interval < 1 {
+			return true
+		}
+		// Both are midnight UTC, so whole days apart
+		offset := int(date.Sub(dates.Of(r.Anchor)).Hours() / 24)
+		return ((offset%r.Interval)+r.Interval)%r.Interval == 0
+	case ScheduleMonthDays:
+		// Days past the end of a short month fall on its last day
+		last := dates.StartOfMonth(date).AddDate(0, 1, -1).Day()
+		for _, day := range r.Days {
+			if min(day, last) == date.Day() {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// Expected counts the occurrences the rule expects from from to to, and how
+// many of them completions fulfilled. Day-based schedules expect each
+// scheduled day; completions on other days don't count. Frequency schedules
+// expect the goal in every period, less its skipped days and cut down to the
+// number of active days of a period that fall inside the range, and
+// completions count up to it.
+func Expected(rule Rule, completed []time.Time, from, to time.Time) (expected int, met int) {
+	from = dates.Of(from)
+	to = dates.Of(to)
+	if to.Before(from) {
+		return 0, 0
+	}
+
+	done := make(map[time.Time]bool, len(completed))
+	for _, date := range completed {
+		done[dates.Of(date)] = true
+	}
+
+	if rule.IsDayBased() || rule.FrequencyUnit == Daily || rule.FrequencyUnit == "" {
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			if !rule.IsScheduled(day) {
+				continue
+			}
+			expected++
+			if done[day] {
+				met++
+			}
+		}
+		return expected, met
+	}
+
+	for p := periodStart(rule, from); !p.After(to); p = nextPeriod(rule, p) {
+		start, end := p, nextPeriod(rule, p).AddDate(0, 0, -1)
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+
+		target := periodTarget(rule, start, end)
+		count := 0
+		for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+			if done[day] {
+				count++
+			}
+		}
+
+		expected += target
+		met += min(count, target)
+	}
+
+	return expected, met
+}
+
+// computeDays walks the days from from to today and finds the current and
+// longest runs of scheduled days that were completed. Unscheduled and frozen
+// days neither extend nor break a run, and today doesn't break it while it
+// can still be completed.
+func computeDays(rule Rule, completed []time.Time, from, today time.Time) Result {
+	done := make(map[time.Time]bool, len(completed))
+	for _, date := range completed {
+		date = dates.Of(date)
+		if date.After(today) {
+			continue
+		}
+		if date.Before(from) {
+			from = date
+		}
+		done[date] = true
+	}
+
+	result := Result{Unit: "days"}
+
+	var run int
+	var runStart, runEnd time.Time
+	var days []time.Time
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if !rule.IsScheduled(day) {
+			continue
+		}
+
+		if done[day] {
+			if run == 0 {
+				runStart = day
+				days = nil
+			}
+			run++
+			runEnd = day
+			days = append(days, day)
+
+			if run > result.Longest.Length {
+				result.Longest = dayRun(run, runStart, runEnd)
+			}
+			continue
+		}
+
+		// Today can still be completed
+		if rule.Frozen[day] || day.Equal(today) {
+			continue
+		}
+		if run > 0 {
+			result.Breaks = append(result.Breaks, day)
+		}
+		run = 0
+	}
+
+	if run > 0 {
+		result.Current = dayRun(run, runStart, runEnd)
+		result.Periods = days
+	}
+
+	return result
+}
+
+// activeDays counts the days from start to end on which the habit is active
+func activeDays(rule Rule, start, end time.Time) int {
+	if rule.Start.IsZero() && rule.End.IsZero() && len(rule.Pauses) == 0 && len(rule.Skipped) == 0 {
+		return dates.DaysBetween(start, end)
+	}
+
+	count := 0
+	for day := dates.Of(start); !day.After(dates.Of(end)); day = day.AddDate(0, 0, 1) {
+		if rule.IsActive(day) {
+			count++
+		}
+	}
+	return count
+}
+
+// dayRun builds a run spanning the scheduled days from first to last
+func dayRun(length int, first, last time.Time) Run {
+	return Run{Length: length, StartDate: &first, EndDate: &last}
+}
+
+// isoWeekday numbers the days of the week from Monday=1 to Sunday=7
+func isoWeekday(date time.Time) int {
+	if date.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(date.Weekday())
+}
+
+// containsDay reports whether day is in days
+func containsDay(days []int, day int) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
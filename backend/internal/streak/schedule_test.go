@@ -0,0 +1,143 @@
+package streak
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsScheduled(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		day  string
+		want bool
+	}{
+		{"frequency every day", Rule{FrequencyUnit: Weekly, Goal: 2}, "2026-03-04", true},
+		{"weekday listed", Rule{Schedule: ScheduleWeekdays, Days: []int{1, 3}}, "2026-03-04", true},
+		{"weekday not listed", Rule{Schedule: ScheduleWeekdays, Days: []int{1, 3}}, "2026-03-05", false},
+		{"sunday is seven", Rule{Schedule: ScheduleWeekdays, Days: []int{7}}, "2026-03-08", true},
+		{"interval anchor", Rule{Schedule: ScheduleInterval, Interval: 3, Anchor: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)}, "2026-03-02", true},
+		{"interval step", Rule{Schedule: ScheduleInterval, Interval: 3, Anchor: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)}, "2026-03-08", true},
+		{"interval between", Rule{Schedule: ScheduleInterval, Interval: 3, Anchor: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)}, "2026-03-07", false},
+		{"interval before anchor", Rule{Schedule: ScheduleInterval, Interval: 3, Anchor: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)}, "2026-02-27", true},
+		{"interval off before anchor", Rule{Schedule: ScheduleInterval, Interval: 3, Anchor: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)}, "2026-03-01", false},
+		{"month day", Rule{Schedule: ScheduleMonthDays, Days: []int{15}}, "2026-03-15", true},
+		{"31st clamps to february", Rule{Schedule: ScheduleMonthDays, Days: []int{31}}, "2026-02-28", true},
+		{"31st clamps to april", Rule{Schedule: ScheduleMonthDays, Days: []int{31}}, "2026-04-30", true},
+		{"31st not before month end", Rule{Schedule: ScheduleMonthDays, Days: []int{31}}, "2026-02-27", false},
+		{"30th clamps to leap day", Rule{Schedule: ScheduleMonthDays, Days: []int{30}}, "2028-02-29", true},
+		{"30th not on 28th of leap year", Rule{Schedule: ScheduleMonthDays, Days: []int{30}}, "2028-02-28", false},
+		{"after end date", Rule{End: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)}, "2026-03-04", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.IsScheduled(d(t, tt.day)); got != tt.want {
+				t.Errorf("IsScheduled(%s) = %v, want %v", tt.day, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpected(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      func(t *testing.T) Rule
+		completed []string
+		from, to  string
+		expected  int
+		met       int
+	}{
+		{
+			name:      "daily counts every day",
+			rule:      func(*testing.T) Rule { return Rule{FrequencyUnit: Daily} },
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04", "2026-03-06"},
+			from:      "2026-03-02",
+			to:        "2026-03-08",
+			expected:  7,
+			met:       4,
+		},
+		{
+			name: "daily less skipped days",
+			rule: func(t *testing.T) Rule {
+				return Rule{FrequencyUnit: Daily, Skipped: map[time.Time]bool{d(t, "2026-03-05"): true}}
+			},
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04", "2026-03-06"},
+			from:      "2026-03-02",
+			to:        "2026-03-08",
+			expected:  6,
+			met:       4,
+		},
+		{
+			name:      "weekly goal",
+			rule:      func(*testing.T) Rule { return Rule{FrequencyUnit: Weekly, Goal: 3, WeekStart: "monday"} },
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04", "2026-03-05", "2026-03-09"},
+			from:      "2026-03-02",
+			to:        "2026-03-15",
+			expected:  6,
+			met:       4,
+		},
+		{
+			name: "weekly goal less skipped days",
+			rule: func(t *testing.T) Rule {
+				return Rule{FrequencyUnit: Weekly, Goal: 3, WeekStart: "monday", Skipped: map[time.Time]bool{d(t, "2026-03-11"): true}}
+			},
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04", "2026-03-05", "2026-03-09"},
+			from:      "2026-03-02",
+			to:        "2026-03-15",
+			expected:  5,
+			met:       4,
+		},
+		{
+			name:      "weekly goal cut to the days in range",
+			rule:      func(*testing.T) Rule { return Rule{FrequencyUnit: Weekly, Goal: 3, WeekStart: "monday"} },
+			completed: []string{"2026-03-07", "2026-03-08"},
+			from:      "2026-03-07",
+			to:        "2026-03-08",
+			expected:  2,
+			met:       2,
+		},
+		{
+			name: "weekly goal cut to active days",
+			rule: func(t *testing.T) Rule {
+				return Rule{
+					FrequencyUnit: Weekly,
+					Goal:          3,
+					WeekStart:     "monday",
+					Pauses:        []Pause{{Start: d(t, "2026-03-02"), End: d(t, "2026-03-06")}},
+				}
+			},
+			completed: []string{"2026-03-07"},
+			from:      "2026-03-02",
+			to:        "2026-03-08",
+			expected:  2,
+			met:       1,
+		},
+		{
+			name:      "weekdays count scheduled days only",
+			rule:      func(*testing.T) Rule { return Rule{Schedule: ScheduleWeekdays, Days: []int{1, 3, 5}} },
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-06"},
+			from:      "2026-03-02",
+			to:        "2026-03-08",
+			expected:  3,
+			met:       2,
+		},
+		{
+			name:     "empty range",
+			rule:     func(*testing.T) Rule { return Rule{FrequencyUnit: Daily} },
+			from:     "2026-03-08",
+			to:       "2026-03-02",
+			expected: 0,
+			met:      0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, met := Expected(tt.rule(t), days(t, tt.completed...), d(t, tt.from), d(t, tt.to))
+			if expected != tt.expected || met != tt.met {
+				t.Errorf("Expected = %d, %d; want %d, %d", expected, met, tt.expected, tt.met)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package streak
+
+import (
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+)
+
+// Frequency units a habit's goal can be set in
+const (
+	Daily   = dates.Daily
+	Weekly  = dates.Weekly
+	Monthly = dates.Monthly
+)
+
+// Rule describes what it takes to keep a habit's streak going
+type Rule struct {
+	FrequencyUnit string             // Daily, Weekly or Monthly
+	Goal          int                // Completions needed per period; daily habits need one
+	WeekStart     string             // First day of the week for weekly goals
+	Schedule      string             // One of the Schedule constants; empty means ScheduleFrequency
+	Days          []int              // Weekdays (Monday=1 to Sunday=7) or days of the month
+	Interval      int                // Days between occurrences of an interval schedule
+	Anchor        time.Time          // First occurrence of an interval schedule
+	Start         time.Time          // First day the habit is expected on; zero if unbounded
+	End           time.Time          // Last day the habit is expected on; zero if unbounded
+	Pauses        []Pause            // Days the habit is not expected on
+	Skipped       map[time.Time]bool // Excused days, neither successes nor misses
+	Frozen        map[time.Time]bool // Missed days, or first days of missed periods, a streak freeze covers
+}
+
+// Pause is a range of days on which a habit is not expected
+type Pause struct {
+	Start time.Time
+	End   time.Time // Zero while the pause is open-ended
+}
+
+// Run is a sequence of consecutive periods in which the goal was met
+type Run struct {
+	Length    int        `json:"length"`
+	StartDate *time.Time `json:"start_date"`
+	EndDate   *time.Time `json:"end_date"`
+}
+
+// Result is the outcome of walking a habit's history
+type Result struct {
+	Unit    string      `json:"unit"` // "days", "weeks" or "months"
+	Current Run         `json:"current"`
+	Longest Run         `json:"longest"`
+	Breaks  []time.Time `json:"-"` // Missed days, or first days of missed periods, that ended a run, oldest first
+	Periods []time.Time `json:"-"` // Days, or first days of periods, the current run is made of, oldest first
+}
+
+// Compute walks every period from the one containing from up to the one
+// containing today and finds the current and longest runs of periods in
+// which the goal was met. Periods without enough completions, including
+// days with no record at all, break a run. The period in progress only
+// extends the current streak once its goal is met; until then the streak
+// ending with the previous period still counts as current. Each skipped day
+// excuses one completion of its period's goal. Periods that are entirely
+// paused, skipped or outside the habit's start and end dates are passed
+// over, and so are missed periods a streak freeze covers. Day-based
+// schedules count runs of scheduled days instead.
+//
+// completed holds the calendar dates on which the habit was completed, in
+// any order. Dates after today are ignored.
+func Compute(rule Rule, completed []time.Time, from, today time.Time) Result {
+	today = dates.Of(today)
+	from = dates.Of(from)
+
+	if rule.IsDayBased() {
+		return computeDays(rule, completed, from, today)
+	}
+
+	// Completions per period, keyed by the period's first day
+	counts := make(map[time.Time]int)
+	for _, date := range completed {
+		date = dates.Of(date)
+		if date.After(today) {
+			continue
+		}
+		if date.Before(from) {
+			from = date
+		}
+		counts[periodStart(rule, date)]++
+	}
+
+	result := Result{Unit: unitName(rule.FrequencyUnit)}
+	last := periodStart(rule, today)
+
+	var run int
+	var runStart, runEnd time.Time
+	var periods []time.Time
+	for p := periodStart(rule, from); !p.After(last); p = nextPeriod(rule, p) {
+		// Periods the habit isn't expected in at all are passed over
+		target := periodTarget(rule, p, nextPeriod(rule, p).AddDate(0, 0, -1))
+		if target == 0 {
+			continue
+		}
+
+		if counts[p] >= target {
+			if run == 0 {
+				runStart = p
+				periods = nil
+			}
+			run++
+			runEnd = p
+			periods = append(periods, p)
+
+			if run > result.Longest.Length {
+				result.Longest = newRun(rule, run, runStart, runEnd, today)
+			}
+			continue
+		}
+
+		// A frozen period keeps the run going without extending it, and an
+		// unfinished current period doesn't break it
+		if rule.Frozen[p] || p.Equal(last) {
+			continue
+		}
+		if run > 0 {
+			result.Breaks = append(result.Breaks, p)
+		}
+		run = 0
+	}
+
+	if run > 0 {
+		result.Current = newRun(rule, run, runStart, runEnd, today)
+		result.Periods = periods
+	}
+
+	return result
+}
+
+// newRun builds a run spanning the periods from first to last, with the
+// end date capped at today
+func newRun(rule Rule, length int, first, last, today time.Time) Run {
+	start := first
+	end := nextPeriod(rule, last).AddDate(0, 0, -1)
+	if end.After(today) {
+		end = today
+	}
+	return Run{Length: length, StartDate: &start, EndDate: &end}
+}
+
+// goal returns the number of completions needed per period
+func goal(rule Rule) int {
+	if rule.FrequencyUnit == Daily || rule.FrequencyUnit == "" || rule.Goal < 1 {
+		return 1
+	}
+	if rule.FrequencyUnit == Weekly && rule.Goal > 7 {
+		return 7
+	}
+	return rule.Goal
+}
+
+// periodTarget returns the completions expected from start to end within a
+// period: its goal less one per skipped day, and no more than the days the
+// habit is active on
+func periodTarget(rule Rule, start, end time.Time) int {
+	skipped := 0
+	for date := range rule.Skipped {
+		if !date.Before(start) && !date.After(end) {
+			skipped++
+		}
+	}
+	return max(min(goal(rule)-skipped, activeDays(rule, start, end)), 0)
+}
+
+// periodStart returns the first day of the period containing date
+func periodStart(rule Rule, date time.Time) time.Time {
+	start, _ := dates.PeriodBounds(rule.FrequencyUnit, date, rule.WeekStart)
+	return start
+}
+
+// nextPeriod returns the first day of the period after the one starting at start
+func nextPeriod(rule Rule, start time.Time) time.Time {
+	_, end := dates.PeriodBounds(rule.FrequencyUnit, start, rule.WeekStart)
+	return end.AddDate(0, 0, 1)
+}
+
+// unitName names the periods a streak is counted in
+func unitName(frequencyUnit string) string {
+	switch frequencyUnit {
+	case Weekly:
+		return "weeks"
+	case Monthly:
+		return "months"
+	default:
+		return "days"
+	}
+}
+
+// CleanDays returns the days from from to today on which no relapse
+// occurred and the rule expects the habit at all. A habit to break succeeds
+// on every such day, without any check-in, so its streaks are computed from
+// these days.
+func CleanDays(rule Rule, relapses []time.Time, from, today time.Time) []time.Time {
+	from = dates.Of(from)
+	today = dates.Of(today)
+
+	relapsed := make(map[time.Time]bool, len(relapses))
+	for _, date := range relapses {
+		relapsed[dates.Of(date)] = true
+	}
+
+	clean := make([]time.Time, 0)
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if !relapsed[day] && rule.IsActive(day) {
+			clean = append(clean, day)
+		}
+	}
+	return clean
+}
+
+// DateSet returns the calendar dates of days as a set
+func DateSet(days []time.Time) map[time.Time]bool {
+	if len(days) == 0 {
+		return nil
+	}
+
+	set := make(map[time.Time]bool, len(days))
+	for _, date := range days {
+		set[dates.Of(date)] = true
+	}
+	return set
+}
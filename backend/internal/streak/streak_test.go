@@ -0,0 +1,460 @@
+package streak
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// d parses a YYYY-MM-DD date, failing the test on a typo
+func d(t *testing.T, s string) time.Time {
+	t.Helper()
+	date, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return date
+}
+
+// days parses a list of YYYY-MM-DD dates
+func days(t *testing.T, s ...string) []time.Time {
+	t.Helper()
+	list := make([]time.Time, 0, len(s))
+	for _, date := range s {
+		list = append(list, d(t, date))
+	}
+	return list
+}
+
+// run describes an expected Run by its length and YYYY-MM-DD bounds
+type run struct {
+	length     int
+	start, end string
+}
+
+func checkRun(t *testing.T, name string, got Run, want run) {
+	t.Helper()
+	if got.Length != want.length {
+		t.Errorf("%s length = %d, want %d", name, got.Length, want.length)
+	}
+	if want.length == 0 {
+		if got.StartDate != nil || got.EndDate != nil {
+			t.Errorf("%s has dates, want none", name)
+		}
+		return
+	}
+	if got.StartDate == nil || got.EndDate == nil {
+		t.Errorf("%s has no dates", name)
+		return
+	}
+	if start := got.StartDate.Format("2006-01-02"); start != want.start {
+		t.Errorf("%s start = %s, want %s", name, start, want.start)
+	}
+	if end := got.EndDate.Format("2006-01-02"); end != want.end {
+		t.Errorf("%s end = %s, want %s", name, end, want.end)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	// 2026-03-02 is a Monday
+	daily := Rule{FrequencyUnit: Daily}
+	weekly := Rule{FrequencyUnit: Weekly, Goal: 3, WeekStart: "monday"}
+	monthly := Rule{FrequencyUnit: Monthly, Goal: 2}
+	monthEnd := Rule{Schedule: ScheduleMonthDays, Days: []int{31}}
+
+	tests := []struct {
+		name      string
+		rule      func(t *testing.T) Rule
+		completed []string
+		from      string
+		today     string
+		unit      string
+		current   run
+		longest   run
+	}{
+		{
+			name:      "daily miss breaks the run",
+			rule:      func(*testing.T) Rule { return daily },
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04", "2026-03-06", "2026-03-07"},
+			from:      "2026-03-02",
+			today:     "2026-03-08",
+			unit:      "days",
+			current:   run{2, "2026-03-06", "2026-03-07"},
+			longest:   run{3, "2026-03-02", "2026-03-04"},
+		},
+		{
+			name:      "daily today extends once completed",
+			rule:      func(*testing.T) Rule { return daily },
+			completed: []string{"2026-03-06", "2026-03-07", "2026-03-08"},
+			from:      "2026-03-06",
+			today:     "2026-03-08",
+			unit:      "days",
+			current:   run{3, "2026-03-06", "2026-03-08"},
+			longest:   run{3, "2026-03-06", "2026-03-08"},
+		},
+		{
+			name:      "daily miss yesterday ends the current run",
+			rule:      func(*testing.T) Rule { return daily },
+			completed: []string{"2026-03-05", "2026-03-06"},
+			from:      "2026-03-05",
+			today:     "2026-03-08",
+			unit:      "days",
+			longest:   run{2, "2026-03-05", "2026-03-06"},
+		},
+		{
+			name:      "daily completions on one day count once",
+			rule:      func(*testing.T) Rule { return daily },
+			completed: []string{"2026-03-07", "2026-03-07", "2026-03-08"},
+			from:      "2026-03-07",
+			today:     "2026-03-08",
+			unit:      "days",
+			current:   run{2, "2026-03-07", "2026-03-08"},
+			longest:   run{2, "2026-03-07", "2026-03-08"},
+		},
+		{
+			name:      "weekly goal met in consecutive weeks",
+			rule:      func(*testing.T) Rule { return weekly },
+			completed: []string{"2026-03-02", "2026-03-04", "2026-03-06", "2026-03-09", "2026-03-10", "2026-03-15"},
+			from:      "2026-03-02",
+			today:     "2026-03-16",
+			unit:      "weeks",
+			current:   run{2, "2026-03-02", "2026-03-15"},
+			longest:   run{2, "2026-03-02", "2026-03-15"},
+		},
+		{
+			name:      "weekly short week breaks the run",
+			rule:      func(*testing.T) Rule { return weekly },
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04", "2026-03-09", "2026-03-10"},
+			from:      "2026-03-02",
+			today:     "2026-03-16",
+			unit:      "weeks",
+			longest:   run{1, "2026-03-02", "2026-03-08"},
+		},
+		{
+			name:      "monthly goal across months",
+			rule:      func(*testing.T) Rule { return monthly },
+			completed: []string{"2026-01-05", "2026-01-20", "2026-02-01", "2026-02-28"},
+			from:      "2026-01-01",
+			today:     "2026-03-10",
+			unit:      "months",
+			current:   run{2, "2026-01-01", "2026-02-28"},
+			longest:   run{2, "2026-01-01", "2026-02-28"},
+		},
+		{
+			name: "daily paused day is passed over",
+			rule: func(t *testing.T) Rule {
+				rule := daily
+				rule.Pauses = []Pause{{Start: d(t, "2026-03-05"), End: d(t, "2026-03-05")}}
+				return rule
+			},
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04", "2026-03-06", "2026-03-07"},
+			from:      "2026-03-02",
+			today:     "2026-03-08",
+			unit:      "days",
+			current:   run{5, "2026-03-02", "2026-03-07"},
+			longest:   run{5, "2026-03-02", "2026-03-07"},
+		},
+		{
+			name: "daily open-ended pause covers today",
+			rule: func(t *testing.T) Rule {
+				rule := daily
+				rule.Pauses = []Pause{{Start: d(t, "2026-03-05")}}
+				return rule
+			},
+			completed: []string{"2026-03-03", "2026-03-04"},
+			from:      "2026-03-03",
+			today:     "2026-03-10",
+			unit:      "days",
+			current:   run{2, "2026-03-03", "2026-03-04"},
+			longest:   run{2, "2026-03-03", "2026-03-04"},
+		},
+		{
+			name: "daily skipped day is excused",
+			rule: func(t *testing.T) Rule {
+				rule := daily
+				rule.Skipped = map[time.Time]bool{d(t, "2026-03-05"): true}
+				return rule
+			},
+			completed: []string{"2026-03-04", "2026-03-06"},
+			from:      "2026-03-04",
+			today:     "2026-03-07",
+			unit:      "days",
+			current:   run{2, "2026-03-04", "2026-03-06"},
+			longest:   run{2, "2026-03-04", "2026-03-06"},
+		},
+		{
+			name: "daily before the start date isn't expected",
+			rule: func(t *testing.T) Rule {
+				rule := daily
+				rule.Start = d(t, "2026-03-05")
+				return rule
+			},
+			completed: []string{"2026-03-05", "2026-03-06"},
+			from:      "2026-03-01",
+			today:     "2026-03-06",
+			unit:      "days",
+			current:   run{2, "2026-03-05", "2026-03-06"},
+			longest:   run{2, "2026-03-05", "2026-03-06"},
+		},
+		{
+			name: "daily after the end date isn't expected",
+			rule: func(t *testing.T) Rule {
+				rule := daily
+				rule.End = d(t, "2026-03-04")
+				return rule
+			},
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04"},
+			from:      "2026-03-02",
+			today:     "2026-03-08",
+			unit:      "days",
+			current:   run{3, "2026-03-02", "2026-03-04"},
+			longest:   run{3, "2026-03-02", "2026-03-04"},
+		},
+		{
+			name: "weekly skipped day lowers the goal",
+			rule: func(t *testing.T) Rule {
+				rule := weekly
+				rule.Skipped = map[time.Time]bool{d(t, "2026-03-11"): true}
+				return rule
+			},
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04", "2026-03-09", "2026-03-10"},
+			from:      "2026-03-02",
+			today:     "2026-03-16",
+			unit:      "weeks",
+			current:   run{2, "2026-03-02", "2026-03-15"},
+			longest:   run{2, "2026-03-02", "2026-03-15"},
+		},
+		{
+			name: "weekly paused week is passed over",
+			rule: func(t *testing.T) Rule {
+				rule := weekly
+				rule.Pauses = []Pause{{Start: d(t, "2026-03-09"), End: d(t, "2026-03-15")}}
+				return rule
+			},
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04", "2026-03-16", "2026-03-17", "2026-03-18"},
+			from:      "2026-03-02",
+			today:     "2026-03-18",
+			unit:      "weeks",
+			current:   run{2, "2026-03-02", "2026-03-18"},
+			longest:   run{2, "2026-03-02", "2026-03-18"},
+		},
+		{
+			name:      "month days clamp to short months",
+			rule:      func(*testing.T) Rule { return monthEnd },
+			completed: []string{"2026-01-31", "2026-02-28", "2026-03-31"},
+			from:      "2026-01-01",
+			today:     "2026-04-15",
+			unit:      "days",
+			current:   run{3, "2026-01-31", "2026-03-31"},
+			longest:   run{3, "2026-01-31", "2026-03-31"},
+		},
+		{
+			name:      "month days miss on a clamped day",
+			rule:      func(*testing.T) Rule { return monthEnd },
+			completed: []string{"2026-01-31", "2026-02-27", "2026-03-31"},
+			from:      "2026-01-01",
+			today:     "2026-04-15",
+			unit:      "days",
+			current:   run{1, "2026-03-31", "2026-03-31"},
+			longest:   run{1, "2026-01-31", "2026-01-31"},
+		},
+		{
+			name:      "weekdays ignore unscheduled days",
+			rule:      func(*testing.T) Rule { return Rule{Schedule: ScheduleWeekdays, Days: []int{1, 3, 5}} },
+			completed: []string{"2026-03-02", "2026-03-04", "2026-03-06", "2026-03-09"},
+			from:      "2026-03-02",
+			today:     "2026-03-10",
+			unit:      "days",
+			current:   run{4, "2026-03-02", "2026-03-09"},
+			longest:   run{4, "2026-03-02", "2026-03-09"},
+		},
+		{
+			name: "interval every third day",
+			rule: func(t *testing.T) Rule {
+				return Rule{Schedule: ScheduleInterval, Interval: 3, Anchor: d(t, "2026-03-02")}
+			},
+			completed: []string{"2026-03-02", "2026-03-05", "2026-03-08"},
+			from:      "2026-03-02",
+			today:     "2026-03-10",
+			unit:      "days",
+			current:   run{3, "2026-03-02", "2026-03-08"},
+			longest:   run{3, "2026-03-02", "2026-03-08"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Compute(tt.rule(t), days(t, tt.completed...), d(t, tt.from), d(t, tt.today))
+
+			if result.Unit != tt.unit {
+				t.Errorf("unit = %q, want %q", result.Unit, tt.unit)
+			}
+			checkRun(t, "current", result.Current, tt.current)
+			checkRun(t, "longest", result.Longest, tt.longest)
+		})
+	}
+}
+
+func TestCleanDays(t *testing.T) {
+	rule := Rule{
+		FrequencyUnit: Daily,
+		Pauses:        []Pause{{Start: d(t, "2026-03-05"), End: d(t, "2026-03-05")}},
+		Skipped:       map[time.Time]bool{d(t, "2026-03-07"): true},
+	}
+
+	got := CleanDays(rule, days(t, "2026-03-04", "2026-03-06"), d(t, "2026-03-02"), d(t, "2026-03-08"))
+	want := days(t, "2026-03-02", "2026-03-03", "2026-03-08")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CleanDays = %v, want %v", got, want)
+	}
+}
+
+func TestComputeBreaks(t *testing.T) {
+	// 2026-03-02 is a Monday
+	daily := Rule{FrequencyUnit: Daily}
+	weekly := Rule{FrequencyUnit: Weekly, Goal: 3, WeekStart: "monday"}
+	monthEnd := Rule{Schedule: ScheduleMonthDays, Days: []int{31}}
+
+	tests := []struct {
+		name      string
+		rule      func(t *testing.T) Rule
+		completed []string
+		from      string
+		today     string
+		current   run
+		breaks    []string
+		periods   []string
+	}{
+		{
+			name:      "daily miss breaks the run",
+			rule:      func(*testing.T) Rule { return daily },
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04", "2026-03-06", "2026-03-07"},
+			from:      "2026-03-02",
+			today:     "2026-03-08",
+			current:   run{2, "2026-03-06", "2026-03-07"},
+			breaks:    []string{"2026-03-05"},
+			periods:   []string{"2026-03-06", "2026-03-07"},
+		},
+		{
+			name:      "daily miss yesterday ends the current run",
+			rule:      func(*testing.T) Rule { return daily },
+			completed: []string{"2026-03-05", "2026-03-06"},
+			from:      "2026-03-05",
+			today:     "2026-03-08",
+			breaks:    []string{"2026-03-07"},
+		},
+		{
+			name:      "daily misses without a run aren't breaks",
+			rule:      func(*testing.T) Rule { return daily },
+			completed: []string{"2026-03-02", "2026-03-06"},
+			from:      "2026-03-02",
+			today:     "2026-03-06",
+			current:   run{1, "2026-03-06", "2026-03-06"},
+			breaks:    []string{"2026-03-03"},
+			periods:   []string{"2026-03-06"},
+		},
+		{
+			name: "daily frozen miss joins the runs without extending them",
+			rule: func(t *testing.T) Rule {
+				rule := daily
+				rule.Frozen = map[time.Time]bool{d(t, "2026-03-05"): true}
+				return rule
+			},
+			completed: []string{"2026-03-03", "2026-03-04", "2026-03-06"},
+			from:      "2026-03-03",
+			today:     "2026-03-07",
+			current:   run{3, "2026-03-03", "2026-03-06"},
+			periods:   []string{"2026-03-03", "2026-03-04", "2026-03-06"},
+		},
+		{
+			name: "daily skipped day isn't a period of the run",
+			rule: func(t *testing.T) Rule {
+				rule := daily
+				rule.Skipped = map[time.Time]bool{d(t, "2026-03-05"): true}
+				return rule
+			},
+			completed: []string{"2026-03-04", "2026-03-06"},
+			from:      "2026-03-04",
+			today:     "2026-03-07",
+			current:   run{2, "2026-03-04", "2026-03-06"},
+			periods:   []string{"2026-03-04", "2026-03-06"},
+		},
+		{
+			name:      "weekly short week breaks the run",
+			rule:      func(*testing.T) Rule { return weekly },
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04", "2026-03-09", "2026-03-10"},
+			from:      "2026-03-02",
+			today:     "2026-03-16",
+			breaks:    []string{"2026-03-09"},
+		},
+		{
+			name: "weekly frozen week keeps the run going",
+			rule: func(t *testing.T) Rule {
+				rule := weekly
+				rule.Frozen = map[time.Time]bool{d(t, "2026-03-09"): true}
+				return rule
+			},
+			completed: []string{"2026-03-02", "2026-03-03", "2026-03-04", "2026-03-16", "2026-03-17", "2026-03-18"},
+			from:      "2026-03-02",
+			today:     "2026-03-18",
+			current:   run{2, "2026-03-02", "2026-03-18"},
+			periods:   []string{"2026-03-02", "2026-03-16"},
+		},
+		{
+			name:      "month days miss on a clamped day",
+			rule:      func(*testing.T) Rule { return monthEnd },
+			completed: []string{"2026-01-31", "2026-02-27", "2026-03-31"},
+			from:      "2026-01-01",
+			today:     "2026-04-15",
+			current:   run{1, "2026-03-31", "2026-03-31"},
+			breaks:    []string{"2026-02-28"},
+			periods:   []string{"2026-03-31"},
+		},
+		{
+			name: "month days frozen clamped day",
+			rule: func(t *testing.T) Rule {
+				rule := monthEnd
+				rule.Frozen = map[time.Time]bool{d(t, "2026-02-28"): true}
+				return rule
+			},
+			completed: []string{"2026-01-31", "2026-03-31"},
+			from:      "2026-01-01",
+			today:     "2026-04-15",
+			current:   run{2, "2026-01-31", "2026-03-31"},
+			periods:   []string{"2026-01-31", "2026-03-31"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Compute(tt.rule(t), days(t, tt.completed...), d(t, tt.from), d(t, tt.today))
+
+			checkRun(t, "current", result.Current, tt.current)
+			if want := days(t, tt.breaks...); !sameDays(result.Breaks, want) {
+				t.Errorf("breaks = %v, want %v", result.Breaks, want)
+			}
+			if want := days(t, tt.periods...); !sameDays(result.Periods, want) {
+				t.Errorf("periods = %v, want %v", result.Periods, want)
+			}
+		})
+	}
+}
+
+// sameDays compares date lists, treating nil and empty alike
+func sameDays(got, want []time.Time) bool {
+	if len(got) == 0 && len(want) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+func TestDateSet(t *testing.T) {
+	if DateSet(nil) != nil {
+		t.Error("DateSet(nil) is not nil")
+	}
+
+	set := DateSet([]time.Time{time.Date(2026, 3, 2, 15, 30, 0, 0, time.FixedZone("UTC+9", 9*3600))})
+	if !set[d(t, "2026-03-02")] || len(set) != 1 {
+		t.Errorf("DateSet = %v, want only 2026-03-02", set)
+	}
+}
@@ -0,0 +1,84 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// pruneEvery is how many recorded failures pass between sweeps of stale
+// counters
+const pruneEvery = 1000
+
+// MemoryStore keeps counters in process memory. Counters are not shared
+// between instances and are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+	writes  int
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*Entry)}
+}
+
+// Get returns the counter for a key
+func (s *MemoryStore) Get(key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok {
+		return *entry, nil
+	}
+	return Entry{}, nil
+}
+
+// RecordFailure increments the counter for a key
+func (s *MemoryStore) RecordFailure(key string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes++
+	if s.writes%pruneEvery == 0 {
+		s.prune(now, window)
+	}
+
+	entry, ok := s.entries[key]
+	if !ok || now.Sub(entry.LastFailure) > window {
+		entry = &Entry{}
+		s.entries[key] = entry
+	}
+
+	entry.Failures++
+	entry.LastFailure = now
+	return entry.Failures, nil
+}
+
+// Lock blocks a key until the given time
+func (s *MemoryStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok {
+		entry.LockedUntil = until
+	}
+	return nil
+}
+
+// Reset clears the counter for a key
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// prune drops counters that are past their window and no longer locked
+func (s *MemoryStore) prune(now time.Time, window time.Duration) {
+	for key, entry := range s.entries {
+		if now.Sub(entry.LastFailure) > window && now.After(entry.LockedUntil) {
+			delete(s.entries, key)
+		}
+	}
+}
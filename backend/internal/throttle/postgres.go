@@ -0,0 +1,85 @@
+package throttle
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PostgresStore keeps counters in the auth_throttle table so that every
+// instance of the API shares them
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+// NewPostgresStore creates a store backed by the database
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+// Get returns the counter for a key
+func (s *PostgresStore) Get(key string) (Entry, error) {
+	query := `
+        SELECT failures, last_failure_at, locked_until
+        FROM auth_throttle
+        WHERE key = $1`
+
+	var entry Entry
+	var lockedUntil sql.NullTime
+
+	err := s.DB.QueryRow(query, key).Scan(&entry.Failures, &entry.LastFailure, &lockedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Entry{}, nil
+		}
+		return Entry{}, err
+	}
+
+	if lockedUntil.Valid {
+		entry.LockedUntil = lockedUntil.Time
+	}
+
+	return entry, nil
+}
+
+// RecordFailure increments the counter for a key in a single statement, so
+// concurrent failures from several instances are all counted
+func (s *PostgresStore) RecordFailure(key string, now time.Time, window time.Duration) (int, error) {
+	query := `
+        INSERT INTO auth_throttle (key, failures, last_failure_at)
+        VALUES ($1, 1, $2)
+        ON CONFLICT (key) DO UPDATE SET
+            failures = CASE
+                WHEN auth_throttle.last_failure_at < $3 THEN 1
+                ELSE auth_throttle.failures + 1
+            END,
+            last_failure_at = EXCLUDED.last_failure_at
+        RETURNING failures`
+
+	var failures int
+	err := s.DB.QueryRow(query, key, now, now.Add(-window)).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+
+	// Stale rows are cleaned up opportunistically
+	if failures == 1 {
+		_, _ = s.DB.Exec(
+			"DELETE FROM auth_throttle WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $2)",
+			now.Add(-window), now,
+		)
+	}
+
+	return failures, nil
+}
+
+// Lock blocks a key until the given time
+func (s *PostgresStore) Lock(key string, until time.Time) error {
+	_, err := s.DB.Exec("UPDATE auth_throttle SET locked_until = $1 WHERE key = $2", until, key)
+	return err
+}
+
+// Reset clears the counter for a key
+func (s *PostgresStore) Reset(key string) error {
+	_, err := s.DB.Exec("DELETE FROM auth_throttle WHERE key = $1", key)
+	return err
+}
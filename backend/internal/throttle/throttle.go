@@ -0,0 +1,172 @@
+package throttle
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/config"
+)
+
+// counterWindow is how long a counter is kept after its last failure
+const counterWindow = 24 * time.Hour
+
+// Entry is the failure counter stored for one key
+type Entry struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store keeps failure counters. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Get returns the counter for a key, or a zero Entry if there is none
+	Get(key string) (Entry, error)
+	// RecordFailure increments a counter and returns the new failure count.
+	// Counters whose last failure is older than window start over.
+	RecordFailure(key string, now time.Time, window time.Duration) (int, error)
+	// Lock blocks a key until the given time
+	Lock(key string, until time.Time) error
+	// Reset clears a counter
+	Reset(key string) error
+}
+
+// Rule describes how many failures are free and how the lockout grows
+type Rule struct {
+	Free int           // Failures allowed before any lockout
+	Base time.Duration // Lockout after the first failure past Free
+	Max  time.Duration // Upper bound for the exponentially growing lockout
+}
+
+// Default rules. IP addresses are allowed more failures than accounts since
+// many users can share one address.
+var (
+	AccountRule      = Rule{Free: 5, Base: 30 * time.Second, Max: time.Hour}
+	IPRule           = Rule{Free: 20, Base: 30 * time.Second, Max: time.Hour}
+	ResetRequestRule = Rule{Free: 3, Base: time.Minute, Max: time.Hour}
+)
+
+// Key is a counter key together with its rule
+type Key struct {
+	Name string
+	Rule Rule
+}
+
+// AccountKey builds the key counting failures of an action for an account
+func AccountKey(action string, account string, rule Rule) Key {
+	return Key{Name: action + ":account:" + strings.ToLower(strings.TrimSpace(account)), Rule: rule}
+}
+
+// IPKey builds the key counting failures of an action from an IP address
+func IPKey(action string, ip string, rule Rule) Key {
+	return Key{Name: action + ":ip:" + ip, Rule: rule}
+}
+
+// Limiter applies exponential backoff and temporary lockouts to keys.
+// Store errors are logged and the limiter fails open, so an unavailable
+// store never locks everybody out.
+type Limiter struct {
+	store  Store
+	window time.Duration
+}
+
+// NewLimiter creates a limiter. Counters are forgotten after window passes
+// without a failure.
+func NewLimiter(store Store, window time.Duration) *Limiter {
+	return &Limiter{store: store, window: window}
+}
+
+// New creates a limiter with the store selected by the configuration
+func New(cfg *config.Config, db *sql.DB) *Limiter {
+	switch cfg.ThrottleStore {
+	case "postgres":
+		return NewLimiter(NewPostgresStore(db), counterWindow)
+	default:
+		return NewLimiter(NewMemoryStore(), counterWindow)
+	}
+}
+
+// Check returns how long the caller must wait before trying again, or zero
+// if none of the keys is locked
+func (l *Limiter) Check(keys ...Key) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+
+	for _, key := range keys {
+		entry, err := l.store.Get(key.Name)
+		if err != nil {
+			log.Printf("Failed to read throttle counter: %v", err)
+			continue
+		}
+
+		if remaining := entry.LockedUntil.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+
+	return wait
+}
+
+// Fail records a failed attempt for every key and returns the longest
+// lockout that resulted from it
+func (l *Limiter) Fail(keys ...Key) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+
+	for _, key := range keys {
+		failures, err := l.store.RecordFailure(key.Name, now, l.window)
+		if err != nil {
+			log.Printf("Failed to record throttle failure: %v", err)
+			continue
+		}
+
+		lockout := Backoff(key.Rule, failures)
+		if lockout <= 0 {
+			continue
+		}
+
+		if err := l.store.Lock(key.Name, now.Add(lockout)); err != nil {
+			log.Printf("Failed to lock throttle key: %v", err)
+			continue
+		}
+
+		if lockout > wait {
+			wait = lockout
+		}
+	}
+
+	return wait
+}
+
+// Succeed clears the counters of the keys after a successful attempt
+func (l *Limiter) Succeed(keys ...Key) {
+	for _, key := range keys {
+		if err := l.store.Reset(key.Name); err != nil {
+			log.Printf("Failed to reset throttle counter: %v", err)
+		}
+	}
+}
+
+// Backoff returns the lockout for a failure count under a rule. It doubles
+// with every failure past the free ones, up to the rule's maximum.
+func Backoff(rule Rule, failures int) time.Duration {
+	over := failures - rule.Free
+	if over <= 0 {
+		return 0
+	}
+
+	lockout := rule.Base
+	for i := 1; i < over; i++ {
+		lockout *= 2
+		if lockout >= rule.Max {
+			return rule.Max
+		}
+	}
+
+	if lockout > rule.Max {
+		return rule.Max
+	}
+	return lockout
+}
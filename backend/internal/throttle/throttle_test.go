@@ -0,0 +1,72 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	rule := Rule{Free: 3, Base: 30 * time.Second, Max: 5 * time.Minute}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, 30 * time.Second},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{7, 4 * time.Minute},
+		{8, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(rule, tt.failures); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffBaseAboveMax(t *testing.T) {
+	rule := Rule{Free: 0, Base: time.Hour, Max: time.Minute}
+	if got := Backoff(rule, 1); got != time.Minute {
+		t.Errorf("Backoff = %v, want %v", got, time.Minute)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(), time.Hour)
+	account := AccountKey("login", " Someone@Example.com ", Rule{Free: 2, Base: time.Minute, Max: time.Hour})
+	ip := IPKey("login", "192.0.2.1", Rule{Free: 5, Base: time.Minute, Max: time.Hour})
+
+	if account.Name != "login:account:someone@example.com" {
+		t.Errorf("account key = %q", account.Name)
+	}
+
+	for i := 1; i <= 2; i++ {
+		if wait := limiter.Fail(account, ip); wait != 0 {
+			t.Fatalf("failure %d locked out for %v, want none", i, wait)
+		}
+	}
+	if wait := limiter.Check(account, ip); wait != 0 {
+		t.Fatalf("Check = %v before any lockout", wait)
+	}
+
+	if wait := limiter.Fail(account, ip); wait != time.Minute {
+		t.Fatalf("third failure locked out for %v, want %v", wait, time.Minute)
+	}
+	if wait := limiter.Check(account, ip); wait <= 0 || wait > time.Minute {
+		t.Fatalf("Check = %v, want up to %v", wait, time.Minute)
+	}
+
+	// Success clears only the keys it is given
+	limiter.Succeed(account)
+	if wait := limiter.Check(account); wait != 0 {
+		t.Errorf("Check after success = %v, want 0", wait)
+	}
+	if wait := limiter.Fail(ip); wait != 0 {
+		t.Errorf("IP failure 4 locked out for %v, want none", wait)
+	}
+}
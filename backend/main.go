@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"gitlab.com/KARSTERRR/habitrack/config"
+	"gitlab.com/KARSTERRR/habitrack/internal/maintenance"
+	"gitlab.com/KARSTERRR/habitrack/internal/statsworker"
 	"gitlab.com/KARSTERRR/habitrack/migrations"
 	"gitlab.com/KARSTERRR/habitrack/routes"
 
@@ -20,6 +22,9 @@ func main() {
 		log.Println("No .env file found, using system environment variables")
 	}
 
+	// Load configuration
+	cfg := config.LoadConfig()
+
 	// Initialize database
 	db, err := config.InitDB()
 	if err != nil {
@@ -32,6 +37,12 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Start background maintenance (purging deleted accounts and archived habits)
+	maintenance.Start(db, cfg)
+
+	// Start background statistics recalculation
+	statsworker.Start(db)
+
 	// Set Gin mode based on environment
 	ginMode := os.Getenv("GIN_MODE")
 	if ginMode == "" {
@@ -42,17 +53,25 @@ func main() {
 	// Create new Gin router
 	router := gin.Default()
 
+	// Only believe client addresses forwarded by known proxies, since
+	// per-IP throttling keys on them
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
 	// Configure CORS
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "If-Match"},
+		ExposeHeaders:    []string{"Content-Length", "ETag"},
 		AllowCredentials: true,
 	}))
 
 	// Initialize routes
-	routes.SetupRoutes(router, db)
+	if err := routes.SetupRoutes(router, db); err != nil {
+		log.Fatalf("Failed to set up routes: %v", err)
+	}
 
 	// Get port from environment, default to 8080
 	port := os.Getenv("PORT")
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS email_verification_expires,
+    DROP COLUMN IF EXISTS email_verification_token,
+    DROP COLUMN IF EXISTS email_verified_at;
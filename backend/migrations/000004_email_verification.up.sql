@@ -0,0 +1,8 @@
+-- Add email verification columns to users
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS email_verification_token VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS email_verification_expires TIMESTAMP;
+
+-- Accounts created before verification existed are treated as verified
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
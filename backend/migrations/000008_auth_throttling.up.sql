@@ -0,0 +1,22 @@
+-- Create failed attempt counters table for brute-force protection
+CREATE TABLE IF NOT EXISTS auth_throttle (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+-- Create failed authentication audit log
+CREATE TABLE IF NOT EXISTS auth_failures (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(50) NOT NULL,
+    email VARCHAR(255),
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    reason VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_failures_user_id ON auth_failures(user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_failures_created_at ON auth_failures(created_at);
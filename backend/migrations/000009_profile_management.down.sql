@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deletion_scheduled_at,
+    DROP COLUMN IF EXISTS email_change_expires,
+    DROP COLUMN IF EXISTS email_change_token,
+    DROP COLUMN IF EXISTS pending_email;
@@ -0,0 +1,9 @@
+-- Add pending email change and scheduled deletion columns to users
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS pending_email VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS email_change_token VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS email_change_expires TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL;
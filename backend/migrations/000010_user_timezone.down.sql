@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS week_start,
+    DROP COLUMN IF EXISTS timezone;
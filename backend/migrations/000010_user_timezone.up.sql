@@ -0,0 +1,5 @@
+-- Add time zone and first day of the week to users
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    ADD COLUMN IF NOT EXISTS week_start VARCHAR(10) NOT NULL DEFAULT 'monday'
+        CHECK (week_start IN ('monday', 'sunday', 'saturday'));
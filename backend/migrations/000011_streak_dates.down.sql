@@ -0,0 +1,6 @@
+ALTER TABLE habit_stats
+    DROP COLUMN IF EXISTS longest_streak_end,
+    DROP COLUMN IF EXISTS longest_streak_start,
+    DROP COLUMN IF EXISTS streak_end,
+    DROP COLUMN IF EXISTS streak_start,
+    DROP COLUMN IF EXISTS streak_unit;
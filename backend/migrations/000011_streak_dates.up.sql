@@ -0,0 +1,7 @@
+-- Record the unit and dates of current and longest streaks
+ALTER TABLE habit_stats
+    ADD COLUMN IF NOT EXISTS streak_unit VARCHAR(10) NOT NULL DEFAULT 'days',
+    ADD COLUMN IF NOT EXISTS streak_start DATE,
+    ADD COLUMN IF NOT EXISTS streak_end DATE,
+    ADD COLUMN IF NOT EXISTS longest_streak_start DATE,
+    ADD COLUMN IF NOT EXISTS longest_streak_end DATE;
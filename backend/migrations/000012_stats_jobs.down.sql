@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS stats_refreshed_on;
+
+DROP TABLE IF EXISTS stats_jobs;
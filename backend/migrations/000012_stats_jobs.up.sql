@@ -0,0 +1,17 @@
+-- Create queue of pending statistics recalculations, at most one per habit
+CREATE TABLE IF NOT EXISTS stats_jobs (
+    habit_id INTEGER PRIMARY KEY REFERENCES habits(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    queued_at TIMESTAMP NOT NULL,
+    run_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    -- Jobs that keep failing are set aside instead of being retried forever
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_stats_jobs_run_at ON stats_jobs(run_at);
+
+-- Local date on which each user's stats were last refreshed overnight
+ALTER TABLE users ADD COLUMN IF NOT EXISTS stats_refreshed_on DATE;
@@ -0,0 +1,13 @@
+ALTER TABLE habit_stats
+    DROP COLUMN IF EXISTS target_percent,
+    DROP COLUMN IF EXISTS average_value,
+    DROP COLUMN IF EXISTS total_value;
+
+ALTER TABLE habit_tracks
+    ALTER COLUMN value DROP NOT NULL,
+    ALTER COLUMN value TYPE INTEGER USING ROUND(value);
+
+ALTER TABLE habits
+    DROP COLUMN IF EXISTS aggregation,
+    DROP COLUMN IF EXISTS unit,
+    DROP COLUMN IF EXISTS target_value;
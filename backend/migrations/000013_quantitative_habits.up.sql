@@ -0,0 +1,18 @@
+-- Add target amount, unit and aggregation mode for measurable habits
+ALTER TABLE habits
+    ADD COLUMN IF NOT EXISTS target_value DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (target_value >= 0),
+    ADD COLUMN IF NOT EXISTS unit VARCHAR(20) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS aggregation VARCHAR(10) NOT NULL DEFAULT 'sum'
+        CHECK (aggregation IN ('sum', 'max', 'last'));
+
+-- Allow fractional amounts such as 5.2 km
+UPDATE habit_tracks SET value = 0 WHERE value IS NULL;
+ALTER TABLE habit_tracks
+    ALTER COLUMN value TYPE DOUBLE PRECISION,
+    ALTER COLUMN value SET NOT NULL;
+
+-- Record amount totals alongside completion statistics
+ALTER TABLE habit_stats
+    ADD COLUMN IF NOT EXISTS total_value DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS average_value DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS target_percent DOUBLE PRECISION NOT NULL DEFAULT 0;
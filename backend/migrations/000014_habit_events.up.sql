@@ -0,0 +1,18 @@
+-- Create append-only log of check-ins; habit_tracks holds one row per day
+-- derived from these events
+CREATE TABLE IF NOT EXISTS habit_events (
+    id SERIAL PRIMARY KEY,
+    habit_id INTEGER NOT NULL REFERENCES habits(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    completed BOOLEAN NOT NULL DEFAULT false,
+    value DOUBLE PRECISION NOT NULL DEFAULT 0,
+    notes TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_habit_events_habit_id_date ON habit_events(habit_id, date);
+
+-- Each existing day becomes a single event
+INSERT INTO habit_events (habit_id, date, occurred_at, completed, value, notes)
+SELECT habit_id, date, date::timestamp, completed, value, COALESCE(notes, '')
+FROM habit_tracks;
@@ -0,0 +1,8 @@
+ALTER TABLE habit_stats
+    DROP COLUMN IF EXISTS met_count,
+    DROP COLUMN IF EXISTS expected_count;
+
+ALTER TABLE habits
+    DROP COLUMN IF EXISTS schedule_interval,
+    DROP COLUMN IF EXISTS schedule_days,
+    DROP COLUMN IF EXISTS schedule_type;
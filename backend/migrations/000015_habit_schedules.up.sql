@@ -0,0 +1,12 @@
+-- Add schedules: N times per period, specific weekdays, every N days or
+-- specific days of the month
+ALTER TABLE habits
+    ADD COLUMN IF NOT EXISTS schedule_type VARCHAR(20) NOT NULL DEFAULT 'frequency'
+        CHECK (schedule_type IN ('frequency', 'weekdays', 'interval', 'monthdays')),
+    ADD COLUMN IF NOT EXISTS schedule_days VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS schedule_interval INTEGER NOT NULL DEFAULT 0;
+
+-- Record expected and fulfilled occurrences behind the success rate
+ALTER TABLE habit_stats
+    ADD COLUMN IF NOT EXISTS expected_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS met_count INTEGER NOT NULL DEFAULT 0;
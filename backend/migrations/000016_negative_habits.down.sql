@@ -0,0 +1,4 @@
+-- Clean days removed from habits to break are not restored
+ALTER TABLE habit_stats
+    DROP COLUMN IF EXISTS last_relapse_at,
+    DROP COLUMN IF EXISTS days_clean;
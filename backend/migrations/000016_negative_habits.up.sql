@@ -0,0 +1,22 @@
+-- Record how long a habit to break has gone without a relapse
+ALTER TABLE habit_stats
+    ADD COLUMN IF NOT EXISTS days_clean INTEGER,
+    ADD COLUMN IF NOT EXISTS last_relapse_at TIMESTAMP;
+
+-- Habits to break used to be tracked like habits to build, so re-derive
+-- their existing days. A yes/no day checked off as completed was a clean
+-- day, which is now implied by having no check-ins at all; a day of a habit
+-- with a daily limit is completed when its amount stays within the limit.
+DELETE FROM habit_events e
+USING habit_tracks t, habits h
+WHERE t.habit_id = e.habit_id AND t.date = e.date AND h.id = t.habit_id
+    AND h.type = 'negative' AND h.target_value = 0 AND t.completed;
+
+DELETE FROM habit_tracks t
+USING habits h
+WHERE h.id = t.habit_id AND h.type = 'negative' AND h.target_value = 0 AND t.completed;
+
+UPDATE habit_tracks t
+SET completed = t.value <= h.target_value
+FROM habits h
+WHERE h.id = t.habit_id AND h.type = 'negative' AND h.target_value > 0;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS habit_pauses;
+
+ALTER TABLE habits
+    DROP COLUMN IF EXISTS end_date,
+    DROP COLUMN IF EXISTS start_date;
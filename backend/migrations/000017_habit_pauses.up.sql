@@ -0,0 +1,18 @@
+-- Let habits start and end on given days
+ALTER TABLE habits
+    ADD COLUMN IF NOT EXISTS start_date DATE,
+    ADD COLUMN IF NOT EXISTS end_date DATE;
+
+-- Periods a habit, or every habit of a user when habit_id is NULL, is paused
+CREATE TABLE IF NOT EXISTS habit_pauses (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    habit_id INTEGER REFERENCES habits(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    end_date DATE,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    CHECK (end_date IS NULL OR end_date >= start_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_habit_pauses_user_id ON habit_pauses(user_id);
@@ -0,0 +1,10 @@
+ALTER TABLE habit_stats
+    DROP COLUMN IF EXISTS skipped_days;
+
+ALTER TABLE habit_tracks
+    DROP COLUMN IF EXISTS reason,
+    DROP COLUMN IF EXISTS status;
+
+ALTER TABLE habit_events
+    DROP COLUMN IF EXISTS reason,
+    DROP COLUMN IF EXISTS status;
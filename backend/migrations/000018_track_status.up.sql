@@ -0,0 +1,26 @@
+-- Let check-ins set the day's status explicitly, e.g. to skip a sick day
+ALTER TABLE habit_events
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT ''
+        CHECK (status IN ('', 'completed', 'partial', 'skipped', 'missed')),
+    ADD COLUMN IF NOT EXISTS reason TEXT NOT NULL DEFAULT '';
+
+-- Track days as completed, partial, skipped or missed instead of a boolean
+ALTER TABLE habit_tracks
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'missed'
+        CHECK (status IN ('completed', 'partial', 'skipped', 'missed')),
+    ADD COLUMN IF NOT EXISTS reason TEXT NOT NULL DEFAULT '';
+
+-- Existing days are completed or missed, or partial when a measurable habit
+-- to build made progress short of its target
+UPDATE habit_tracks t
+SET status = CASE
+        WHEN t.completed THEN 'completed'
+        WHEN h.type = 'positive' AND h.target_value > 0 AND t.value > 0 THEN 'partial'
+        ELSE 'missed'
+    END
+FROM habits h
+WHERE h.id = t.habit_id;
+
+-- Record excused days alongside completion statistics
+ALTER TABLE habit_stats
+    ADD COLUMN IF NOT EXISTS skipped_days INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,20 @@
+-- Ledger of streak freezes a user earned with long runs and spent on misses
+CREATE TABLE IF NOT EXISTS streak_freezes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    habit_id INTEGER REFERENCES habits(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('earned', 'forfeited', 'spent')),
+    date DATE NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_streak_freezes_user_id ON streak_freezes(user_id);
+CREATE INDEX IF NOT EXISTS idx_streak_freezes_habit_id ON streak_freezes(habit_id, kind, date);
+
+-- A miss is covered at most once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_streak_freezes_spent
+    ON streak_freezes(habit_id, date) WHERE kind = 'spent';
+
+-- A milestone is credited at most once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_streak_freezes_credited
+    ON streak_freezes(habit_id, date) WHERE kind IN ('earned', 'forfeited');
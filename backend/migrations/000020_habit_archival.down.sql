@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_habits_archived_at;
+
+ALTER TABLE habits
+    DROP COLUMN IF EXISTS archived_at;
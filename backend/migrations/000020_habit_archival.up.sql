@@ -0,0 +1,9 @@
+-- Record when habits were archived, so they can be purged after a retention
+-- period. Habits archived before now count from today, so that none of
+-- them is purged before its owner has had the full period to restore it.
+ALTER TABLE habits
+    ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+
+UPDATE habits SET archived_at = NOW() WHERE is_archived = true AND archived_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_habits_archived_at ON habits(archived_at) WHERE is_archived = true;
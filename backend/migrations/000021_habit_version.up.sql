@@ -0,0 +1,3 @@
+-- Version habits so that concurrent edits can be detected
+ALTER TABLE habits
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
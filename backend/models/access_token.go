@@ -0,0 +1,281 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Token scopes that can be granted to personal access tokens
+const (
+	ScopeHabitsRead    = "habits:read"
+	ScopeHabitsWrite   = "habits:write"
+	ScopeTrackingRead  = "tracking:read"
+	ScopeTrackingWrite = "tracking:write"
+)
+
+// ValidScopes lists every scope a personal access token may hold
+var ValidScopes = []string{ScopeHabitsRead, ScopeHabitsWrite, ScopeTrackingRead, ScopeTrackingWrite}
+
+// PersonalAccessTokenPrefix starts every personal access token so that the
+// auth middleware can tell them apart from JWTs
+const PersonalAccessTokenPrefix = "htp_"
+
+// PersonalAccessToken model for long-lived, scoped API tokens
+type PersonalAccessToken struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Name        string     `json:"name"`
+	TokenHash   string     `json:"-"`
+	TokenPrefix string     `json:"token_prefix"` // First characters of the token, for display
+	Scopes      []string   `json:"scopes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"-"`
+}
+
+// IsActive reports whether the token is neither revoked nor expired
+func (t *PersonalAccessToken) IsActive() bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || time.Now().Before(*t.ExpiresAt)
+}
+
+// HasScope reports whether the token was granted a scope
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidScope reports whether a scope name is known
+func IsValidScope(scope string) bool {
+	for _, s := range ValidScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessTokenRepository handles database operations for personal access tokens
+type AccessTokenRepository struct {
+	DB *sql.DB
+}
+
+// NewAccessTokenRepository creates a new personal access token repository
+func NewAccessTokenRepository(db *sql.DB) *AccessTokenRepository {
+	return &AccessTokenRepository{DB: db}
+}
+
+// Create inserts a new personal access token in the database
+func (r *AccessTokenRepository) Create(token *PersonalAccessToken) error {
+	token.CreatedAt = time.Now()
+
+	query := `
+        INSERT INTO personal_access_tokens (
+            user_id, name, token_hash, token_prefix, scopes, created_at, expires_at
+        )
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id`
+
+	err := r.DB.QueryRow(
+		query,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		token.TokenPrefix,
+		strings.Join(token.Scopes, ","),
+		token.CreatedAt,
+		token.ExpiresAt,
+	).Scan(&token.ID)
+
+	return err
+}
+
+// GetByHash retrieves a personal access token by its hash
+func (r *AccessTokenRepository) GetByHash(tokenHash string) (*PersonalAccessToken, error) {
+	query := `
+        SELECT id, user_id, name, token_hash, token_prefix, scopes,
+            created_at, expires_at, last_used_at, revoked_at
+        FROM personal_access_tokens
+        WHERE token_hash = $1`
+
+	token, err := scanAccessToken(r.DB.QueryRow(query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("access token not found")
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// GetByID retrieves a personal access token of a user
+func (r *AccessTokenRepository) GetByID(id int64, userID int64) (*PersonalAccessToken, error) {
+	query := `
+        SELECT id, user_id, name, token_hash, token_prefix, scopes,
+            created_at, expires_at, last_used_at, revoked_at
+        FROM personal_access_tokens
+        WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	token, err := scanAccessToken(r.DB.QueryRow(query, id, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("access token not found")
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// GetAllByUser retrieves the unrevoked personal access tokens of a user
+func (r *AccessTokenRepository) GetAllByUser(userID int64) ([]*PersonalAccessToken, error) {
+	query := `
+        SELECT id, user_id, name, token_hash, token_prefix, scopes,
+            created_at, expires_at, last_used_at, revoked_at
+        FROM personal_access_tokens
+        WHERE user_id = $1 AND revoked_at IS NULL
+        ORDER BY created_at DESC`
+
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]*PersonalAccessToken, 0)
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// UpdateName renames a personal access token
+func (r *AccessTokenRepository) UpdateName(id int64, userID int64, name string) error {
+	query := `
+        UPDATE personal_access_tokens
+        SET name = $1
+        WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`
+
+	result, err := r.DB.Exec(query, name, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("access token not found")
+	}
+
+	return nil
+}
+
+// Touch records use of a token, at most once a minute
+func (r *AccessTokenRepository) Touch(id int64) error {
+	now := time.Now()
+	query := `
+        UPDATE personal_access_tokens
+        SET last_used_at = $1
+        WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $3)`
+
+	_, err := r.DB.Exec(query, now, id, now.Add(-time.Minute))
+	return err
+}
+
+// Revoke revokes a personal access token of a user
+func (r *AccessTokenRepository) Revoke(id int64, userID int64) error {
+	query := `
+        UPDATE personal_access_tokens
+        SET revoked_at = $1
+        WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`
+
+	result, err := r.DB.Exec(query, time.Now(), id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("access token not found or already revoked")
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every personal access token of a user
+func (r *AccessTokenRepository) RevokeAllForUser(userID int64) error {
+	query := `
+        UPDATE personal_access_tokens
+        SET revoked_at = $1
+        WHERE user_id = $2 AND revoked_at IS NULL`
+
+	_, err := r.DB.Exec(query, time.Now(), userID)
+	return err
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAccessToken scans a single personal access token row
+func scanAccessToken(row rowScanner) (*PersonalAccessToken, error) {
+	token := &PersonalAccessToken{}
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenHash,
+		&token.TokenPrefix,
+		&scopes,
+		&token.CreatedAt,
+		&expiresAt,
+		&lastUsedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = strings.Split(scopes, ",")
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return token, nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Authentication actions recorded in the audit log
+const (
+	AuthActionLogin              = "login"
+	AuthActionMFA                = "mfa"
+	AuthActionResetRequest       = "password_reset_request"
+	AuthActionResetPassword      = "password_reset"
+	AuthActionResendVerification = "verification_resend"
+	AuthActionReauth             = "reauth" // Password re-entered for a sensitive account change
+)
+
+// AuthFailure is an audit record of a failed or refused authentication attempt
+type AuthFailure struct {
+	ID        int64     `json:"id"`
+	UserID    *int64    `json:"user_id"`
+	Action    string    `json:"action"`
+	Email     string    `json:"email"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuthFailureRepository handles database operations for the auth failure log
+type AuthFailureRepository struct {
+	DB *sql.DB
+}
+
+// NewAuthFailureRepository creates a new auth failure repository
+func NewAuthFailureRepository(db *sql.DB) *AuthFailureRepository {
+	return &AuthFailureRepository{DB: db}
+}
+
+// Create inserts a new auth failure record in the database
+func (r *AuthFailureRepository) Create(failure *AuthFailure) error {
+	failure.CreatedAt = time.Now()
+
+	query := `
+        INSERT INTO auth_failures (user_id, action, email, ip_address, user_agent, reason, created_at)
+        VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, $7)
+        RETURNING id`
+
+	err := r.DB.QueryRow(
+		query,
+		failure.UserID,
+		failure.Action,
+		failure.Email,
+		failure.IPAddress,
+		failure.UserAgent,
+		failure.Reason,
+		failure.CreatedAt,
+	).Scan(&failure.ID)
+
+	return err
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// HabitProgress is the tracking of one habit around a given day
+type HabitProgress struct {
+	HabitID        int64
+	CompletedToday bool
+	StatusToday    string // Status of the day's record; empty if nothing was tracked
+	ValueToday     float64
+	WeekCount      int // Completions from the start of the week up to the day
+	MonthCount     int // Completions from the start of the month up to the day
+}
+
+// DashboardRepository runs the aggregate queries behind the dashboard. Each
+// method covers all of a user's active habits in a single query.
+type DashboardRepository struct {
+	DB *sql.DB
+}
+
+// NewDashboardRepository creates a new dashboard repository
+func NewDashboardRepository(db *sql.DB) *DashboardRepository {
+	return &DashboardRepository{DB: db}
+}
+
+// GetProgress retrieves the progress of every active habit of a user on
+// date, keyed by habit ID
+func (r *DashboardRepository) GetProgress(userID int64, date, weekStart, monthStart time.Time) (map[int64]*HabitProgress, error) {
+	query := `
+        SELECT
+            h.id,
+            COALESCE(BOOL_OR(t.completed) FILTER (WHERE t.date = $2), false),
+            COALESCE(MAX(t.status) FILTER (WHERE t.date = $2), ''),
+            COALESCE(MAX(t.value) FILTER (WHERE t.date = $2), 0),
+            COUNT(*) FILTER (WHERE t.completed AND t.date >= $3),
+            COUNT(*) FILTER (WHERE t.completed AND t.date >= $4)
+        FROM habits h
+        LEFT JOIN habit_tracks t
+            ON t.habit_id = h.id AND t.date >= LEAST($3::date, $4::date) AND t.date <= $2
+        WHERE h.user_id = $1 AND h.is_archived = false
+        GROUP BY h.id`
+
+	rows, err := r.DB.Query(query, userID, date, weekStart, monthStart)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := make(map[int64]*HabitProgress)
+	for rows.Next() {
+		p := &HabitProgress{}
+		err := rows.Scan(
+			&p.HabitID,
+			&p.CompletedToday,
+			&p.StatusToday,
+			&p.ValueToday,
+			&p.WeekCount,
+			&p.MonthCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		progress[p.HabitID] = p
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return progress, nil
+}
+
+// GetCompletedDates retrieves the dates up to and including until on which
+// each active habit to build of a user was completed, keyed by habit ID
+func (r *DashboardRepository) GetCompletedDates(userID int64, until time.Time) (map[int64][]time.Time, error) {
+	query := `
+        SELECT t.habit_id, t.date
+        FROM habit_tracks t
+        JOIN habits h ON h.id = t.habit_id
+        WHERE h.user_id = $1 AND h.is_archived = false AND h.type = 'positive'
+          AND t.completed = true AND t.date <= $2
+        ORDER BY t.habit_id, t.date ASC`
+
+	return queryHabitDates(r.DB, query, userID, until)
+}
+
+// GetRelapseDates retrieves the dates up to and including until on which
+// each active habit to break of a user was relapsed, keyed by habit ID
+func (r *DashboardRepository) GetRelapseDates(userID int64, until time.Time) (map[int64][]time.Time, error) {
+	query := `
+        SELECT t.habit_id, t.date
+        FROM habit_tracks t
+        JOIN habits h ON h.id = t.habit_id
+        WHERE h.user_id = $1 AND h.is_archived = false AND h.type = 'negative'
+          AND t.status = 'missed' AND t.date <= $2
+        ORDER BY t.habit_id, t.date ASC`
+
+	return queryHabitDates(r.DB, query, userID, until)
+}
+
+// GetSkippedDates retrieves the dates up to and including until on which
+// each active habit of a user was skipped, keyed by habit ID
+func (r *DashboardRepository) GetSkippedDates(userID int64, until time.Time) (map[int64][]time.Time, error) {
+	query := `
+        SELECT t.habit_id, t.date
+        FROM habit_tracks t
+        JOIN habits h ON h.id = t.habit_id
+        WHERE h.user_id = $1 AND h.is_archived = false
+          AND t.status = 'skipped' AND t.date <= $2
+        ORDER BY t.habit_id, t.date ASC`
+
+	return queryHabitDates(r.DB, query, userID, until)
+}
+
+// queryHabitDates runs a query selecting habit IDs and dates, grouping the
+// dates by habit ID
+func queryHabitDates(db *sql.DB, query string, args ...any) (map[int64][]time.Time, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byHabit := make(map[int64][]time.Time)
+	for rows.Next() {
+		var habitID int64
+		var date time.Time
+		if err := rows.Scan(&habitID, &date); err != nil {
+			return nil, err
+		}
+		byHabit[habitID] = append(byHabit[habitID], date)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return byHabit, nil
+}
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+)
+
+// ErrEventNotFound is returned when a check-in doesn't exist on the habit
+var ErrEventNotFound = errors.New("event not found")
+
+// HabitEvent is a single check-in. A day's tracking record is derived from
+// all of its events, so check-ins never overwrite each other.
+type HabitEvent struct {
+	ID         int64     `json:"id"`
+	HabitID    int64     `json:"habit_id"`
+	Date       time.Time `json:"date"`                                                               // Day the check-in counts towards
+	OccurredAt time.Time `json:"occurred_at"`                                                        // When the check-in was made
+	Completed  bool      `json:"completed"`                                                          // Ignored for quantitative habits and habits to break
+	Status     string    `json:"status" validate:"omitempty,oneof=completed partial skipped missed"` // Sets the day's status; empty derives it
+	Reason     string    `json:"reason" validate:"max=200"`
+	Value      float64   `json:"value"`
+	Notes      string    `json:"notes" validate:"max=500"`
+}
+
+// EventRepository handles database operations for habit events. Every
+// change rebuilds the affected days of habit_tracks in the same transaction.
+type EventRepository struct {
+	DB *sql.DB
+}
+
+// NewEventRepository creates a new event repository
+func NewEventRepository(db *sql.DB) *EventRepository {
+	return &EventRepository{DB: db}
+}
+
+// Create appends a check-in and returns the resulting record of its day
+func (r *EventRepository) Create(habit *Habit, event *HabitEvent) (*HabitTrackRecord, error) {
+	tx, err := r.begin(habit.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO habit_events (habit_id, date, occurred_at, completed, status, reason, value, notes)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id`
+
+	err = tx.QueryRow(
+		query,
+		event.HabitID,
+		event.Date,
+		event.OccurredAt,
+		event.Completed,
+		event.Status,
+		event.Reason,
+		event.Value,
+		event.Notes,
+	).Scan(&event.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := rebuildDay(tx, habit, event.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, tx.Commit()
+}
+
+// Delete removes a check-in and returns the resulting record of its day,
+// nil if no check-ins are left on that day
+func (r *EventRepository) Delete(habit *Habit, eventID int64) (*HabitTrackRecord, error) {
+	tx, err := r.begin(habit.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var date time.Time
+	err = tx.QueryRow(
+		"DELETE FROM habit_events WHERE id = $1 AND habit_id = $2 RETURNING date",
+		eventID, habit.ID,
+	).Scan(&date)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrEventNotFound
+		}
+		return nil, err
+	}
+
+	record, err := rebuildDay(tx, habit, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, tx.Commit()
+}
+
+// GetEvents retrieves the check-ins of a habit for a date range, in the
+// order they were made
+func (r *EventRepository) GetEvents(habitID int64, startDate, endDate time.Time) ([]*HabitEvent, error) {
+	query := `
+        SELECT id, habit_id, date, occurred_at, completed, status, reason, value, notes
+        FROM habit_events
+        WHERE habit_id = $1 AND date >= $2 AND date <= $3
+        ORDER BY date ASC, occurred_at ASC, id ASC`
+
+	return queryEvents(r.DB, query, habitID, startDate, endDate)
+}
+
+// RebuildAll derives every tracked day of a habit from its events again,
+// after a change to how the habit's events combine
+func (r *EventRepository) RebuildAll(habit *Habit) error {
+	tx, err := r.begin(habit.ID)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT DISTINCT date FROM habit_events WHERE habit_id = $1", habit.ID)
+	if err != nil {
+		return err
+	}
+
+	days := make([]time.Time, 0)
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			rows.Close()
+			return err
+		}
+		days = append(days, date)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, date := range days {
+		if _, err := rebuildDay(tx, habit, date); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// begin starts a transaction holding the habit's row lock, so that
+// concurrent check-ins on the same habit see each other's events
+func (r *EventRepository) begin(habitID int64) (*sql.Tx, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	var id int64
+	if err := tx.QueryRow("SELECT id FROM habits WHERE id = $1 FOR UPDATE", habitID).Scan(&id); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, errors.New("habit not found")
+		}
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// rebuildDay derives a day's tracking record from its events and stores it,
+// removing the record when no events are left
+func rebuildDay(tx *sql.Tx, habit *Habit, date time.Time) (*HabitTrackRecord, error) {
+	query := `
+        SELECT id, habit_id, date, occurred_at, completed, status, reason, value, notes
+        FROM habit_events
+        WHERE habit_id = $1 AND date = $2
+        ORDER BY occurred_at ASC, id ASC`
+
+	events, err := queryEvents(tx, query, habit.ID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(events) == 0 {
+		_, err := tx.Exec("DELETE FROM habit_tracks WHERE habit_id = $1 AND date = $2", habit.ID, date)
+		return nil, err
+	}
+
+	record := deriveRecord(habit, events)
+	record.Date = dates.Of(date)
+
+	query = `
+        INSERT INTO habit_tracks (habit_id, date, completed, status, reason, value, notes)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (habit_id, date)
+        DO UPDATE SET completed = $3, status = $4, reason = $5, value = $6, notes = $7
+        RETURNING id`
+
+	err = tx.QueryRow(
+		query,
+		record.HabitID,
+		record.Date,
+		record.Completed,
+		record.Status,
+		record.Reason,
+		record.Value,
+		record.Notes,
+	).Scan(&record.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// deriveRecord combines a day's events, in the order they were made. The
+// values combine per the habit's aggregation mode. Quantitative habits are
+// completed once the value reaches the target and partial short of it,
+// others follow the latest event. A habit to break is relapsed, so missed,
+// on any day with events, unless it has a daily limit that the value stays
+// within. The latest event giving a status overrides all of that when it
+// skips the day, and for habits to build without a target.
+func deriveRecord(habit *Habit, events []*HabitEvent) *HabitTrackRecord {
+	record := &HabitTrackRecord{HabitID: habit.ID}
+	notes := make([]string, 0, len(events))
+	status := ""
+
+	for i, event := range events {
+		switch {
+		case i == 0 || habit.Aggregation == AggregateLast:
+			record.Value = event.Value
+		case habit.Aggregation == AggregateMax:
+			record.Value = max(record.Value, event.Value)
+		default:
+			record.Value += event.Value
+		}
+
+		record.Completed = event.Completed
+		if event.Status != "" {
+			status = event.Status
+		}
+		if event.Reason != "" {
+			record.Reason = event.Reason
+		}
+		if event.Notes != "" {
+			notes = append(notes, event.Notes)
+		}
+	}
+
+	switch {
+	case status == TrackSkipped:
+		record.Status = TrackSkipped
+	case habit.IsNegative() && habit.IsQuantitative() && record.Value <= habit.TargetValue:
+		record.Status = TrackCompleted
+	case habit.IsNegative():
+		record.Status = TrackMissed
+	case habit.IsQuantitative() && record.Value >= habit.TargetValue:
+		record.Status = TrackCompleted
+	case habit.IsQuantitative() && record.Value > 0:
+		record.Status = TrackPartial
+	case habit.IsQuantitative():
+		record.Status = TrackMissed
+	case status != "":
+		record.Status = status
+	case record.Completed:
+		record.Status = TrackCompleted
+	default:
+		record.Status = TrackMissed
+	}
+	record.Completed = record.Status == TrackCompleted
+	record.Notes = strings.Join(notes, "\n")
+
+	return record
+}
+
+// eventQuerier is satisfied by both *sql.DB and *sql.Tx
+type eventQuerier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// queryEvents runs a query selecting habit event columns
+func queryEvents(q eventQuerier, query string, args ...any) ([]*HabitEvent, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*HabitEvent, 0)
+	for rows.Next() {
+		event := &HabitEvent{}
+		err := rows.Scan(
+			&event.ID,
+			&event.HabitID,
+			&event.Date,
+			&event.OccurredAt,
+			&event.Completed,
+			&event.Status,
+			&event.Reason,
+			&event.Value,
+			&event.Notes,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
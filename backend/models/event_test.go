@@ -0,0 +1,86 @@
+package models
+
+import "testing"
+
+func TestDeriveRecord(t *testing.T) {
+	glasses := &Habit{Type: PositiveHabit, TargetValue: 8, Aggregation: AggregateSum}
+	run := &Habit{Type: PositiveHabit, TargetValue: 5, Aggregation: AggregateMax}
+	weight := &Habit{Type: PositiveHabit, TargetValue: 70, Aggregation: AggregateLast}
+	stretch := &Habit{Type: PositiveHabit}
+	smoking := &Habit{Type: NegativeHabit}
+	coffee := &Habit{Type: NegativeHabit, TargetValue: 2, Aggregation: AggregateSum}
+
+	tests := []struct {
+		name   string
+		habit  *Habit
+		events []*HabitEvent
+		status string
+		value  float64
+	}{
+		{"target reached", glasses, []*HabitEvent{{Value: 8}}, TrackCompleted, 8},
+		{"target exceeded", glasses, []*HabitEvent{{Value: 10}}, TrackCompleted, 10},
+		{"short of target", glasses, []*HabitEvent{{Value: 3}}, TrackPartial, 3},
+		{"nothing done", glasses, []*HabitEvent{{Value: 0}}, TrackMissed, 0},
+		{"completed flag ignored", glasses, []*HabitEvent{{Value: 3, Completed: true}}, TrackPartial, 3},
+		{"sum adds up", glasses, []*HabitEvent{{Value: 3}, {Value: 5}}, TrackCompleted, 8},
+		{"max keeps best", run, []*HabitEvent{{Value: 6}, {Value: 2}}, TrackCompleted, 6},
+		{"last replaces", weight, []*HabitEvent{{Value: 72}, {Value: 69}}, TrackPartial, 69},
+		{"yes/no done", stretch, []*HabitEvent{{Completed: true}}, TrackCompleted, 0},
+		{"yes/no not done", stretch, []*HabitEvent{{Completed: false}}, TrackMissed, 0},
+		{"latest check-in wins", stretch, []*HabitEvent{{Completed: true}, {Completed: false}}, TrackMissed, 0},
+		{"later check-in completes", stretch, []*HabitEvent{{Completed: false}, {Completed: true}}, TrackCompleted, 0},
+		{"relapse", smoking, []*HabitEvent{{Value: 1}}, TrackMissed, 1},
+		{"relapse marked completed", smoking, []*HabitEvent{{Completed: true}}, TrackMissed, 0},
+		{"within limit", coffee, []*HabitEvent{{Value: 1}, {Value: 1}}, TrackCompleted, 2},
+		{"over limit", coffee, []*HabitEvent{{Value: 2}, {Value: 1}}, TrackMissed, 3},
+		{"skipped", stretch, []*HabitEvent{{Status: TrackSkipped}}, TrackSkipped, 0},
+		{"skip overrides progress", glasses, []*HabitEvent{{Value: 3}, {Status: TrackSkipped}}, TrackSkipped, 3},
+		{"skip overrides relapse", smoking, []*HabitEvent{{Value: 1}, {Status: TrackSkipped}}, TrackSkipped, 1},
+		{"later status replaces skip", stretch, []*HabitEvent{{Status: TrackSkipped}, {Status: TrackCompleted}}, TrackCompleted, 0},
+		{"status without one keeps skip", stretch, []*HabitEvent{{Status: TrackSkipped}, {Completed: true}}, TrackSkipped, 0},
+		{"explicit partial", stretch, []*HabitEvent{{Status: TrackPartial}}, TrackPartial, 0},
+		{"target decides over status", glasses, []*HabitEvent{{Value: 8, Status: TrackMissed}}, TrackCompleted, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := deriveRecord(tt.habit, tt.events)
+			if record.Status != tt.status {
+				t.Errorf("status = %q, want %q", record.Status, tt.status)
+			}
+			if record.Value != tt.value {
+				t.Errorf("value = %v, want %v", record.Value, tt.value)
+			}
+			if record.Completed != (tt.status == TrackCompleted) {
+				t.Errorf("completed = %v, want it to match status %q", record.Completed, tt.status)
+			}
+		})
+	}
+}
+
+func TestDeriveRecordNotes(t *testing.T) {
+	habit := &Habit{Type: PositiveHabit, TargetValue: 8, Aggregation: AggregateSum}
+	events := []*HabitEvent{
+		{Value: 2, Notes: "breakfast"},
+		{Value: 3},
+		{Value: 3, Notes: "gym"},
+	}
+
+	record := deriveRecord(habit, events)
+	if record.Notes != "breakfast\ngym" {
+		t.Errorf("notes = %q, want every check-in's note in order", record.Notes)
+	}
+}
+
+func TestDeriveRecordReason(t *testing.T) {
+	habit := &Habit{Type: PositiveHabit}
+	events := []*HabitEvent{
+		{Status: TrackSkipped, Reason: "sick"},
+		{Status: TrackSkipped},
+	}
+
+	record := deriveRecord(habit, events)
+	if record.Reason != "sick" {
+		t.Errorf("reason = %q, want the latest reason given", record.Reason)
+	}
+}
@@ -0,0 +1,331 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+	"gitlab.com/KARSTERRR/habitrack/internal/streak"
+)
+
+// Kinds of streak freeze ledger entries
+const (
+	FreezeEarned    = "earned"    // A run reached a milestone
+	FreezeForfeited = "forfeited" // A run reached a milestone while the balance was at the cap
+	FreezeSpent     = "spent"     // A miss was covered
+)
+
+const (
+	// FreezeEarnEvery is the run length, in the streak's unit, that earns
+	// a freeze
+	FreezeEarnEvery = 7
+	// FreezeCap is the most unspent freezes a user can hold
+	FreezeCap = 2
+)
+
+// StreakFreeze is an entry of a user's streak freeze ledger. Freezes are
+// earned by the runs of any habit and spent on any habit.
+type StreakFreeze struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	HabitID   *int64    `json:"habit_id"` // Nil once the habit is deleted
+	Kind      string    `json:"kind"`     // One of the Freeze kind constants
+	Date      time.Time `json:"date"`     // Day, or first day of the period, the milestone was reached or the miss happened
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FreezeSummary is a user's streak freeze balance
+type FreezeSummary struct {
+	Balance   int `json:"balance"` // Freezes available to spend
+	Earned    int `json:"earned"`
+	Spent     int `json:"spent"`
+	Forfeited int `json:"forfeited"`
+	Cap       int `json:"cap"`
+	EarnEvery int `json:"earn_every"`
+}
+
+// FreezeRepository handles database operations for the streak freeze ledger
+type FreezeRepository struct {
+	DB *sql.DB
+}
+
+// NewFreezeRepository creates a new streak freeze repository
+func NewFreezeRepository(db *sql.DB) *FreezeRepository {
+	return &FreezeRepository{DB: db}
+}
+
+// GetAllByUser retrieves the ledger of a user, latest first
+func (r *FreezeRepository) GetAllByUser(userID int64) ([]*StreakFreeze, error) {
+	query := `
+        SELECT id, user_id, habit_id, kind, date, created_at
+        FROM streak_freezes
+        WHERE user_id = $1
+        ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	freezes := make([]*StreakFreeze, 0)
+	for rows.Next() {
+		freeze := &StreakFreeze{}
+		var habitID sql.NullInt64
+		err := rows.Scan(
+			&freeze.ID,
+			&freeze.UserID,
+			&habitID,
+			&freeze.Kind,
+			&freeze.Date,
+			&freeze.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if habitID.Valid {
+			freeze.HabitID = &habitID.Int64
+		}
+		freezes = append(freezes, freeze)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return freezes, nil
+}
+
+// GetSummary counts the ledger entries of a user
+func (r *FreezeRepository) GetSummary(userID int64) (*FreezeSummary, error) {
+	query := `
+        SELECT
+            COUNT(*) FILTER (WHERE kind = 'earned'),
+            COUNT(*) FILTER (WHERE kind = 'spent'),
+            COUNT(*) FILTER (WHERE kind = 'forfeited')
+        FROM streak_freezes
+        WHERE user_id = $1`
+
+	summary := &FreezeSummary{Cap: FreezeCap, EarnEvery: FreezeEarnEvery}
+	err := r.DB.QueryRow(query, userID).Scan(&summary.Earned, &summary.Spent, &summary.Forfeited)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Balance = summary.Earned - summary.Spent
+	return summary, nil
+}
+
+// GetFrozenDates retrieves the dates covered by a freeze on each active
+// habit of a user, keyed by habit ID
+func (r *FreezeRepository) GetFrozenDates(userID int64) (map[int64][]time.Time, error) {
+	query := `
+        SELECT f.habit_id, f.date
+        FROM streak_freezes f
+        JOIN habits h ON h.id = f.habit_id
+        WHERE f.user_id = $1 AND h.is_archived = false AND f.kind = 'spent'
+        ORDER BY f.habit_id, f.date ASC`
+
+	return queryHabitDates(r.DB, query, userID)
+}
+
+// GetFrozenDatesByHabit retrieves the dates covered by a freeze on a habit
+func (r *FreezeRepository) GetFrozenDatesByHabit(habitID int64) ([]time.Time, error) {
+	return frozenDates(r.DB, habitID)
+}
+
+// Settle refunds the freezes of a habit whose day is no longer a miss,
+// spends the user's freezes on the misses that ended a run of the habit,
+// oldest first, and then credits the milestones of the habit's current run.
+// A freeze only covers misses after the day it was earned. compute walks the
+// habit's history up to today with the given dates frozen. The ledger is
+// written in tx, so that it is only kept together with whatever the caller
+// derives from it. Returns every date frozen on the habit.
+func (r *FreezeRepository) Settle(tx *sql.Tx, userID int64, habitID int64, compute func(frozen []time.Time) streak.Result) ([]time.Time, error) {
+	// The user's habits share one balance, so settle them one at a time
+	var id int64
+	if err := tx.QueryRow("SELECT id FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&id); err != nil {
+		return nil, err
+	}
+
+	frozen, err := frozenDates(tx, habitID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT date FROM streak_freezes WHERE user_id = $1 AND kind = 'earned'", userID)
+	if err != nil {
+		return nil, err
+	}
+	earned := make([]time.Time, 0)
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		earned = append(earned, dates.Of(date))
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var spent int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM streak_freezes WHERE user_id = $1 AND kind = 'spent'", userID).Scan(&spent); err != nil {
+		return nil, err
+	}
+
+	plan := planFreezes(frozen, earned, spent, compute)
+	for _, date := range plan.Refund {
+		if _, err := tx.Exec("DELETE FROM streak_freezes WHERE habit_id = $1 AND kind = 'spent' AND date = $2", habitID, date); err != nil {
+			return nil, err
+		}
+	}
+	for _, date := range plan.Spend {
+		if _, err := insertFreeze(tx, userID, habitID, FreezeSpent, date); err != nil {
+			return nil, err
+		}
+	}
+	frozen, result := plan.Frozen, plan.Result
+	spent += len(plan.Spend) - len(plan.Refund)
+
+	// Each FreezeEarnEvery periods of the current run earn a freeze, dated
+	// on the period that reached the milestone
+	if run := result.Current; run.StartDate != nil && run.Length >= FreezeEarnEvery {
+		var credited int
+		query := `
+            SELECT COUNT(*)
+            FROM streak_freezes
+            WHERE habit_id = $1 AND kind IN ('earned', 'forfeited') AND date >= $2 AND date <= $3`
+		if err := tx.QueryRow(query, habitID, *run.StartDate, *run.EndDate).Scan(&credited); err != nil {
+			return nil, err
+		}
+
+		for ; credited < run.Length/FreezeEarnEvery; credited++ {
+			kind := FreezeEarned
+			if len(earned)-spent >= FreezeCap {
+				kind = FreezeForfeited
+			}
+			date := dates.Of(result.Periods[(credited+1)*FreezeEarnEvery-1])
+			inserted, err := insertFreeze(tx, userID, habitID, kind, date)
+			if err != nil {
+				return nil, err
+			}
+			if inserted && kind == FreezeEarned {
+				earned = append(earned, date)
+			}
+		}
+	}
+
+	return frozen, nil
+}
+
+// freezePlan is the change to a habit's frozen dates worked out by
+// planFreezes
+type freezePlan struct {
+	Refund []time.Time   // Frozen dates that no longer cover a miss
+	Spend  []time.Time   // Misses to cover with a new freeze
+	Frozen []time.Time   // Every date frozen on the habit afterwards
+	Result streak.Result // The habit's history with Frozen frozen
+}
+
+// planFreezes first refunds the freezes whose day is no longer a miss that
+// ends a run, e.g. because a check-in was added for it later, and then
+// covers the breaks left while the user has freezes earned before them.
+// spent counts the freezes spent across all of the user's habits.
+func planFreezes(frozen []time.Time, earned []time.Time, spent int, compute func(frozen []time.Time) streak.Result) freezePlan {
+	plan := freezePlan{}
+
+	kept := make([]time.Time, 0, len(frozen))
+	for i, date := range frozen {
+		date = dates.Of(date)
+		without := append(append(make([]time.Time, 0, len(frozen)), kept...), frozen[i+1:]...)
+		if !containsDate(compute(without).Breaks, date) {
+			plan.Refund = append(plan.Refund, date)
+			spent--
+			continue
+		}
+		kept = append(kept, date)
+	}
+
+	plan.Result = compute(kept)
+	for i := 0; i < len(plan.Result.Breaks); i++ {
+		date := dates.Of(plan.Result.Breaks[i])
+
+		available := -spent
+		for _, day := range earned {
+			if day.Before(date) {
+				available++
+			}
+		}
+		if available <= 0 {
+			continue
+		}
+
+		plan.Spend = append(plan.Spend, date)
+		spent++
+		kept = append(kept, date)
+
+		// Covering a miss joins two runs, which changes the later breaks
+		plan.Result = compute(kept)
+		i = -1
+	}
+
+	plan.Frozen = kept
+	return plan
+}
+
+// containsDate reports whether days includes the calendar date of date
+func containsDate(days []time.Time, date time.Time) bool {
+	for _, day := range days {
+		if dates.Of(day).Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// insertFreeze appends an entry to a user's ledger. Reports false when the
+// habit already has an entry of the kind for the date.
+func insertFreeze(tx *sql.Tx, userID int64, habitID int64, kind string, date time.Time) (bool, error) {
+	result, err := tx.Exec(
+		"INSERT INTO streak_freezes (user_id, habit_id, kind, date, created_at) VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING",
+		userID, habitID, kind, date, time.Now(),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// frozenDates retrieves the dates covered by a freeze on a habit
+func frozenDates(q eventQuerier, habitID int64) ([]time.Time, error) {
+	rows, err := q.Query("SELECT date FROM streak_freezes WHERE habit_id = $1 AND kind = 'spent' ORDER BY date ASC", habitID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	frozen := make([]time.Time, 0)
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return nil, err
+		}
+		frozen = append(frozen, date)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return frozen, nil
+}
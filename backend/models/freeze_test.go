@@ -0,0 +1,127 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+	"gitlab.com/KARSTERRR/habitrack/internal/streak"
+)
+
+// day parses a YYYY-MM-DD date
+func day(t *testing.T, s string) time.Time {
+	t.Helper()
+	date, err := dates.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return date
+}
+
+// dailyHistory returns a compute function for a daily habit completed on
+// the given days, walked up to today
+func dailyHistory(t *testing.T, today string, completed ...string) func(frozen []time.Time) streak.Result {
+	t.Helper()
+	days := make([]time.Time, len(completed))
+	for i, s := range completed {
+		days[i] = day(t, s)
+	}
+	from, until := days[0], day(t, today)
+
+	return func(frozen []time.Time) streak.Result {
+		rule := streak.Rule{FrequencyUnit: streak.Daily, Frozen: streak.DateSet(frozen)}
+		return streak.Compute(rule, days, from, until)
+	}
+}
+
+func TestPlanFreezesSpendsOnMiss(t *testing.T) {
+	compute := dailyHistory(t, "2026-03-10",
+		"2026-03-01", "2026-03-02", "2026-03-03", "2026-03-04", "2026-03-05", "2026-03-06", "2026-03-07",
+		"2026-03-09", "2026-03-10")
+	earned := []time.Time{day(t, "2026-03-07")}
+
+	plan := planFreezes(nil, earned, 0, compute)
+	if len(plan.Spend) != 1 || !plan.Spend[0].Equal(day(t, "2026-03-08")) {
+		t.Fatalf("spend = %v, want the miss on 2026-03-08", plan.Spend)
+	}
+	if len(plan.Refund) != 0 {
+		t.Errorf("refund = %v, want none", plan.Refund)
+	}
+	if plan.Result.Current.Length != 9 {
+		t.Errorf("current run = %d, want 9 with the miss covered", plan.Result.Current.Length)
+	}
+}
+
+func TestPlanFreezesWithoutBalance(t *testing.T) {
+	compute := dailyHistory(t, "2026-03-10",
+		"2026-03-01", "2026-03-02", "2026-03-03", "2026-03-04", "2026-03-05", "2026-03-06", "2026-03-07",
+		"2026-03-09", "2026-03-10")
+
+	// The only freeze was earned after the miss, or is already spent
+	for name, tc := range map[string]struct {
+		earned []time.Time
+		spent  int
+	}{
+		"earned later": {[]time.Time{day(t, "2026-03-09")}, 0},
+		"spent":        {[]time.Time{day(t, "2026-03-07")}, 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			plan := planFreezes(nil, tc.earned, tc.spent, compute)
+			if len(plan.Spend) != 0 {
+				t.Errorf("spend = %v, want none", plan.Spend)
+			}
+		})
+	}
+}
+
+func TestPlanFreezesRefundsBackfilledDay(t *testing.T) {
+	// The freeze went on 2026-03-08 at midnight; the day was checked off
+	// afterwards
+	compute := dailyHistory(t, "2026-03-10",
+		"2026-03-01", "2026-03-02", "2026-03-03", "2026-03-04", "2026-03-05", "2026-03-06", "2026-03-07",
+		"2026-03-08", "2026-03-09", "2026-03-10")
+	frozen := []time.Time{day(t, "2026-03-08")}
+	earned := []time.Time{day(t, "2026-03-07")}
+
+	plan := planFreezes(frozen, earned, 1, compute)
+	if len(plan.Refund) != 1 || !plan.Refund[0].Equal(day(t, "2026-03-08")) {
+		t.Fatalf("refund = %v, want the freeze on 2026-03-08", plan.Refund)
+	}
+	if len(plan.Spend) != 0 || len(plan.Frozen) != 0 {
+		t.Errorf("spend = %v, frozen = %v, want none", plan.Spend, plan.Frozen)
+	}
+}
+
+func TestPlanFreezesMovesRefundToLaterMiss(t *testing.T) {
+	// 2026-03-08 was covered, then checked off; the freeze is refunded and
+	// spent again on the miss on 2026-03-10
+	compute := dailyHistory(t, "2026-03-12",
+		"2026-03-01", "2026-03-02", "2026-03-03", "2026-03-04", "2026-03-05", "2026-03-06", "2026-03-07",
+		"2026-03-08", "2026-03-09", "2026-03-11", "2026-03-12")
+	frozen := []time.Time{day(t, "2026-03-08")}
+	earned := []time.Time{day(t, "2026-03-07")}
+
+	plan := planFreezes(frozen, earned, 1, compute)
+	if len(plan.Refund) != 1 || !plan.Refund[0].Equal(day(t, "2026-03-08")) {
+		t.Errorf("refund = %v, want the freeze on 2026-03-08", plan.Refund)
+	}
+	if len(plan.Spend) != 1 || !plan.Spend[0].Equal(day(t, "2026-03-10")) {
+		t.Errorf("spend = %v, want the miss on 2026-03-10", plan.Spend)
+	}
+}
+
+func TestPlanFreezesKeepsCoveredMiss(t *testing.T) {
+	compute := dailyHistory(t, "2026-03-10",
+		"2026-03-01", "2026-03-02", "2026-03-03", "2026-03-04", "2026-03-05", "2026-03-06", "2026-03-07",
+		"2026-03-09", "2026-03-10")
+	frozen := []time.Time{day(t, "2026-03-08")}
+	earned := []time.Time{day(t, "2026-03-07")}
+
+	plan := planFreezes(frozen, earned, 1, compute)
+	if len(plan.Refund) != 0 || len(plan.Spend) != 0 {
+		t.Errorf("refund = %v, spend = %v, want no change", plan.Refund, plan.Spend)
+	}
+	if len(plan.Frozen) != 1 {
+		t.Errorf("frozen = %v, want the miss on 2026-03-08", plan.Frozen)
+	}
+}
@@ -3,7 +3,12 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+	"gitlab.com/KARSTERRR/habitrack/internal/streak"
 )
 
 // HabitType represents the type of habit (positive or negative)
@@ -14,6 +19,17 @@ const (
 	NegativeHabit HabitType = "negative" // Habits to break
 )
 
+// How the check-ins of a day combine into the day's value
+const (
+	AggregateSum  = "sum"  // Amounts add up, e.g. glasses of water
+	AggregateMax  = "max"  // The best amount counts, e.g. longest run
+	AggregateLast = "last" // The latest amount replaces earlier ones, e.g. body weight
+)
+
+// ErrHabitVersionConflict is returned when a habit was changed since the
+// version an update is based on
+var ErrHabitVersionConflict = errors.New("habit was modified concurrently")
+
 // Habit model for tracking habits
 type Habit struct {
 	ID          int64     `json:"id"`
@@ -31,15 +47,155 @@ type Habit struct {
 	Color         string  `json:"color" validate:"max=7"` // Color code for UI display
 	Icon          string  `json:"icon" validate:"max=50"` // Icon name for UI display
 	IsArchived    bool    `json:"is_archived"` // Whether habit is archived
+	ArchivedAt    *time.Time `json:"archived_at"` // When the habit was archived; archived habits are purged after the retention period
+	Version       int     `json:"version"` // Incremented by every change, for optimistic concurrency
+	TargetValue   float64 `json:"target_value" validate:"gte=0"` // Amount that completes a day, or the daily limit of a habit to break; 0 for yes/no habits
+	Unit          string  `json:"unit" validate:"max=20"` // Unit of the amount, e.g. "glasses" or "km"
+	Aggregation   string  `json:"aggregation" validate:"omitempty,oneof=sum max last"` // How a day's check-ins combine
+	ScheduleType  string  `json:"schedule_type" validate:"omitempty,oneof=frequency weekdays interval monthdays"` // Which days the habit is due on
+	ScheduleDays  string  `json:"schedule_days" validate:"max=100"` // Comma-separated weekdays (Monday=1, Sunday=7) or days of the month
+	ScheduleInterval int  `json:"schedule_interval" validate:"gte=0"` // Days between occurrences of an interval schedule
+	StartDate     *dates.Date `json:"start_date"` // First day the habit is expected on; defaults to the day it was created
+	EndDate       *dates.Date `json:"end_date"` // Last day the habit is expected on; nil if it never ends
+}
+
+// ValidateSchedule checks that the schedule settings fit the schedule type
+func (h *Habit) ValidateSchedule() error {
+	switch h.ScheduleType {
+	case streak.ScheduleWeekdays, streak.ScheduleMonthDays:
+		days, err := parseScheduleDays(h.ScheduleDays)
+		if err != nil || len(days) == 0 {
+			return errors.New("schedule_days must list at least one day")
+		}
+		limit := 31
+		if h.ScheduleType == streak.ScheduleWeekdays {
+			limit = 7
+		}
+		for _, day := range days {
+			if day < 1 || day > limit {
+				return errors.New("schedule_days must be between 1 and " + strconv.Itoa(limit))
+			}
+		}
+	case streak.ScheduleInterval:
+		if h.ScheduleInterval < 1 {
+			return errors.New("schedule_interval must be at least 1")
+		}
+	}
+	return nil
+}
+
+// Rule returns what it takes to keep the habit going, on its owner's
+// calendar. Interval schedules count from the habit's first day. pauses may
+// hold every pause of the user; those not covering the habit are ignored.
+// skipped holds the dates the habit was skipped on.
+func (h *Habit) Rule(user *User, pauses []*Pause, skipped []time.Time) streak.Rule {
+	days, _ := parseScheduleDays(h.ScheduleDays)
+	rule := streak.Rule{
+		FrequencyUnit: h.FrequencyUnit,
+		Goal:          h.Goal,
+		WeekStart:     user.WeekStart,
+		Schedule:      h.ScheduleType,
+		Days:          days,
+		Interval:      h.ScheduleInterval,
+		Anchor:        h.FirstDay(user.Location()),
+	}
+
+	if h.StartDate != nil {
+		rule.Start = h.StartDate.Time
+	}
+	if h.EndDate != nil {
+		rule.End = h.EndDate.Time
+	}
+
+	rule.Skipped = streak.DateSet(skipped)
+
+	for _, pause := range pauses {
+		if !pause.AppliesTo(h.ID) {
+			continue
+		}
+		p := streak.Pause{Start: pause.StartDate.Time}
+		if pause.EndDate != nil {
+			p.End = pause.EndDate.Time
+		}
+		rule.Pauses = append(rule.Pauses, p)
+	}
+
+	return rule
+}
+
+// FirstDay returns the day the habit's history starts: its start date if
+// set, otherwise the day it was created or the earliest of the tracked dates
+// given, so that backfilled days count. Each of tracked is sorted ascending.
+func (h *Habit) FirstDay(loc *time.Location, tracked ...[]time.Time) time.Time {
+	if h.StartDate != nil {
+		return h.StartDate.Time
+	}
+
+	firstDay := dates.In(h.CreatedAt, loc)
+	for _, history := range tracked {
+		if len(history) > 0 && history[0].Before(firstDay) {
+			firstDay = dates.Of(history[0])
+		}
+	}
+	return firstDay
 }
 
-// HabitTrackRecord model for tracking daily habit completion
+// ValidateDates checks that the habit doesn't end before it starts
+func (h *Habit) ValidateDates() error {
+	if h.StartDate != nil && h.EndDate != nil && h.EndDate.Before(h.StartDate.Time) {
+		return errors.New("end_date must not be before start_date")
+	}
+	return nil
+}
+
+// parseScheduleDays parses a comma-separated list of day numbers
+func parseScheduleDays(s string) ([]int, error) {
+	days := make([]int, 0)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		day, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// IsQuantitative reports whether the habit is measured against a target
+// amount. Completion of such habits is derived from the tracked value; for
+// a habit to break the target is the most allowed per day.
+func (h *Habit) IsQuantitative() bool {
+	return h.TargetValue > 0
+}
+
+// IsNegative reports whether the habit is one to break. Its check-ins are
+// relapses, and days without one count as completed.
+func (h *Habit) IsNegative() bool {
+	return h.Type == NegativeHabit
+}
+
+// Statuses of a tracked day
+const (
+	TrackCompleted = "completed" // The goal or target was reached
+	TrackPartial   = "partial"   // Some progress short of the goal or target
+	TrackSkipped   = "skipped"   // Excused, e.g. a sick or rest day; never a miss
+	TrackMissed    = "missed"    // Not done, or relapsed for a habit to break
+)
+
+// HabitTrackRecord model for tracking daily habit completion, derived from
+// the day's events
 type HabitTrackRecord struct {
 	ID        int64     `json:"id"`
 	HabitID   int64     `json:"habit_id"`
 	Date      time.Time `json:"date"`
-	Completed bool      `json:"completed"`
-	Value     int       `json:"value"` // Optional value (e.g., 8 glasses of water)
+	Completed bool      `json:"completed"` // Whether Status is TrackCompleted
+	Status    string    `json:"status"`    // One of the Track constants
+	Reason    string    `json:"reason"`    // Why the day was skipped or missed
+	Value     float64   `json:"value"`     // Amount tracked (e.g., 8 glasses of water)
 	Notes     string    `json:"notes" validate:"max=500"`
 }
 
@@ -53,9 +209,22 @@ type Stat struct {
 	EndDate      time.Time `json:"end_date"`
 	TotalDays    int       `json:"total_days"`
 	CompletedDays int      `json:"completed_days"`
+	ExpectedCount int      `json:"expected_count"` // Occurrences the schedule expected in the window
+	MetCount     int       `json:"met_count"` // Expected occurrences that were completed
 	SuccessRate  float64   `json:"success_rate"` // Percentage of completion
 	Streak       int       `json:"streak"` // Current streak
 	LongestStreak int      `json:"longest_streak"`
+	StreakUnit   string    `json:"streak_unit"` // "days", "weeks" or "months", following the habit's frequency
+	StreakStart  *time.Time `json:"streak_start_date"`
+	StreakEnd    *time.Time `json:"streak_end_date"`
+	LongestStreakStart *time.Time `json:"longest_streak_start_date"`
+	LongestStreakEnd   *time.Time `json:"longest_streak_end_date"`
+	SkippedDays  int       `json:"skipped_days"` // Excused days, left out of the expected occurrences
+	DaysClean    *int      `json:"days_clean,omitempty"` // Habits to break: days since the latest relapse
+	LastRelapseAt *time.Time `json:"last_relapse_at,omitempty"` // Habits to break: time of the latest relapse
+	TotalValue   float64   `json:"total_value"` // Sum of the values tracked in the window
+	AverageValue float64   `json:"average_value"` // Per elapsed day
+	TargetPercent float64  `json:"target_percent"` // Average value as a percentage of the target; 0 without a target
 	CalculatedAt time.Time `json:"calculated_at"`
 }
 
@@ -74,15 +243,27 @@ func (r *HabitRepository) Create(habit *Habit) error {
 	now := time.Now()
 	habit.CreatedAt = now
 	habit.UpdatedAt = now
+	if habit.Aggregation == "" {
+		habit.Aggregation = AggregateSum
+	}
+	if habit.ScheduleType == "" {
+		habit.ScheduleType = streak.ScheduleFrequency
+	}
+	habit.ArchivedAt = nil
+	if habit.IsArchived {
+		habit.ArchivedAt = &now
+	}
 
 	query := `
         INSERT INTO habits (
             user_id, name, description, type, created_at, updated_at,
             goal, frequency_unit, reminder_enabled, reminder_time, reminder_days,
-            color, icon, is_archived
+            color, icon, is_archived, target_value, unit, aggregation,
+            schedule_type, schedule_days, schedule_interval, start_date, end_date,
+            archived_at
         )
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
-        RETURNING id`
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+        RETURNING id, version`
 
 	err := r.DB.QueryRow(
 		query,
@@ -100,7 +281,16 @@ func (r *HabitRepository) Create(habit *Habit) error {
 		habit.Color,
 		habit.Icon,
 		habit.IsArchived,
-	).Scan(&habit.ID)
+		habit.TargetValue,
+		habit.Unit,
+		habit.Aggregation,
+		habit.ScheduleType,
+		habit.ScheduleDays,
+		habit.ScheduleInterval,
+		habit.StartDate,
+		habit.EndDate,
+		habit.ArchivedAt,
+	).Scan(&habit.ID, &habit.Version)
 
 	return err
 }
@@ -108,11 +298,14 @@ func (r *HabitRepository) Create(habit *Habit) error {
 // GetByID retrieves a habit by ID and user ID (for security)
 func (r *HabitRepository) GetByID(id int64, userID int64) (*Habit, error) {
 	habit := &Habit{}
+	var startDate, endDate, archivedAt sql.NullTime
 	query := `
         SELECT 
             id, user_id, name, description, type, created_at, updated_at,
             goal, frequency_unit, reminder_enabled, reminder_time, reminder_days,
-            color, icon, is_archived
+            color, icon, is_archived, target_value, unit, aggregation,
+            schedule_type, schedule_days, schedule_interval, start_date, end_date,
+            archived_at, version
         FROM habits
         WHERE id = $1 AND user_id = $2`
 
@@ -132,6 +325,16 @@ func (r *HabitRepository) GetByID(id int64, userID int64) (*Habit, error) {
 		&habit.Color,
 		&habit.Icon,
 		&habit.IsArchived,
+		&habit.TargetValue,
+		&habit.Unit,
+		&habit.Aggregation,
+		&habit.ScheduleType,
+		&habit.ScheduleDays,
+		&habit.ScheduleInterval,
+		&startDate,
+		&endDate,
+		&archivedAt,
+		&habit.Version,
 	)
 
 	if err != nil {
@@ -141,12 +344,32 @@ func (r *HabitRepository) GetByID(id int64, userID int64) (*Habit, error) {
 		return nil, err
 	}
 
+	if startDate.Valid {
+		start := dates.NewDate(startDate.Time)
+		habit.StartDate = &start
+	}
+	if endDate.Valid {
+		end := dates.NewDate(endDate.Time)
+		habit.EndDate = &end
+	}
+	if archivedAt.Valid {
+		habit.ArchivedAt = &archivedAt.Time
+	}
+
 	return habit, nil
 }
 
-// Update updates a habit
+// Update updates a habit, provided it is still at habit.Version, and moves
+// it to the next version. Returns ErrHabitVersionConflict if it changed in
+// the meantime. The archival time is kept while the habit stays archived.
 func (r *HabitRepository) Update(habit *Habit) error {
 	habit.UpdatedAt = time.Now()
+	if habit.Aggregation == "" {
+		habit.Aggregation = AggregateSum
+	}
+	if habit.ScheduleType == "" {
+		habit.ScheduleType = streak.ScheduleFrequency
+	}
 
 	query := `
         UPDATE habits
@@ -162,10 +385,22 @@ func (r *HabitRepository) Update(habit *Habit) error {
             reminder_days = $9,
             color = $10, 
             icon = $11, 
-            is_archived = $12
-        WHERE id = $13 AND user_id = $14`
+            is_archived = $12,
+            target_value = $13,
+            unit = $14,
+            aggregation = $15,
+            schedule_type = $16,
+            schedule_days = $17,
+            schedule_interval = $18,
+            start_date = $19,
+            end_date = $20,
+            archived_at = CASE WHEN $12 THEN COALESCE(archived_at, $4) END,
+            version = version + 1
+        WHERE id = $21 AND user_id = $22 AND version = $23
+        RETURNING archived_at, version`
 
-	_, err := r.DB.Exec(
+	var archivedAt sql.NullTime
+	err := r.DB.QueryRow(
 		query,
 		habit.Name,
 		habit.Description,
@@ -179,21 +414,80 @@ func (r *HabitRepository) Update(habit *Habit) error {
 		habit.Color,
 		habit.Icon,
 		habit.IsArchived,
+		habit.TargetValue,
+		habit.Unit,
+		habit.Aggregation,
+		habit.ScheduleType,
+		habit.ScheduleDays,
+		habit.ScheduleInterval,
+		habit.StartDate,
+		habit.EndDate,
 		habit.ID,
 		habit.UserID,
-	)
+		habit.Version,
+	).Scan(&archivedAt, &habit.Version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrHabitVersionConflict
+		}
+		return err
+	}
 
-	return err
+	habit.ArchivedAt = nil
+	if archivedAt.Valid {
+		habit.ArchivedAt = &archivedAt.Time
+	}
+	return nil
+}
+
+// Archive hides a habit from the active habits and stops its reminders and
+// daily stats refresh, keeping its history. Archiving an archived habit
+// keeps its original archival time. Like Update, it only applies to the
+// given version and returns ErrHabitVersionConflict otherwise.
+func (r *HabitRepository) Archive(id int64, userID int64, version int) error {
+	query := `
+        UPDATE habits
+        SET is_archived = true, archived_at = COALESCE(archived_at, $1), updated_at = $1,
+            version = version + 1
+        WHERE id = $2 AND user_id = $3 AND version = $4`
+
+	return r.exec(ErrHabitVersionConflict, query, time.Now(), id, userID, version)
 }
 
-// Delete deletes a habit (soft delete by archiving)
-func (r *HabitRepository) Delete(id int64, userID int64) error {
+// Unarchive makes an archived habit active again, provided it is still at
+// the given version
+func (r *HabitRepository) Unarchive(id int64, userID int64, version int) error {
 	query := `
         UPDATE habits
-        SET is_archived = true, updated_at = $1
-        WHERE id = $2 AND user_id = $3`
+        SET is_archived = false, archived_at = NULL, updated_at = $1,
+            version = version + 1
+        WHERE id = $2 AND user_id = $3 AND version = $4`
+
+	return r.exec(ErrHabitVersionConflict, query, time.Now(), id, userID, version)
+}
+
+// Purge permanently deletes a habit. Its events, tracking records, stats,
+// pauses and queued stats jobs go with it through the ON DELETE CASCADE
+// foreign keys.
+func (r *HabitRepository) Purge(id int64, userID int64) error {
+	return r.exec(errors.New("habit not found"), "DELETE FROM habits WHERE id = $1 AND user_id = $2", id, userID)
+}
+
+// PurgeArchived permanently deletes every habit archived before the given
+// time, along with its history. Returns the number of habits deleted.
+func (r *HabitRepository) PurgeArchived(before time.Time) (int64, error) {
+	result, err := r.DB.Exec("DELETE FROM habits WHERE is_archived = true AND archived_at <= $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
 
-	result, err := r.DB.Exec(query, time.Now(), id, userID)
+// exec runs a statement on a single habit, returning missing if it matched
+// none
+func (r *HabitRepository) exec(missing error, query string, args ...any) error {
+	result, err := r.DB.Exec(query, args...)
 	if err != nil {
 		return err
 	}
@@ -204,7 +498,7 @@ func (r *HabitRepository) Delete(id int64, userID int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("habit not found or already deleted")
+		return missing
 	}
 
 	return nil
@@ -216,7 +510,9 @@ func (r *HabitRepository) GetAllByUser(userID int64, includeArchived bool) ([]*H
         SELECT 
             id, user_id, name, description, type, created_at, updated_at,
             goal, frequency_unit, reminder_enabled, reminder_time, reminder_days,
-            color, icon, is_archived
+            color, icon, is_archived, target_value, unit, aggregation,
+            schedule_type, schedule_days, schedule_interval, start_date, end_date,
+            archived_at, version
         FROM habits
         WHERE user_id = $1`
 
@@ -235,6 +531,7 @@ func (r *HabitRepository) GetAllByUser(userID int64, includeArchived bool) ([]*H
 	habits := make([]*Habit, 0)
 	for rows.Next() {
 		habit := &Habit{}
+		var startDate, endDate, archivedAt sql.NullTime
 		err := rows.Scan(
 			&habit.ID,
 			&habit.UserID,
@@ -251,10 +548,32 @@ func (r *HabitRepository) GetAllByUser(userID int64, includeArchived bool) ([]*H
 			&habit.Color,
 			&habit.Icon,
 			&habit.IsArchived,
+			&habit.TargetValue,
+			&habit.Unit,
+			&habit.Aggregation,
+			&habit.ScheduleType,
+			&habit.ScheduleDays,
+			&habit.ScheduleInterval,
+			&startDate,
+			&endDate,
+			&archivedAt,
+			&habit.Version,
 		)
 		if err != nil {
 			return nil, err
 		}
+
+		if startDate.Valid {
+			start := dates.NewDate(startDate.Time)
+			habit.StartDate = &start
+		}
+		if endDate.Valid {
+			end := dates.NewDate(endDate.Time)
+			habit.EndDate = &end
+		}
+		if archivedAt.Valid {
+			habit.ArchivedAt = &archivedAt.Time
+		}
 		habits = append(habits, habit)
 	}
 
@@ -275,31 +594,10 @@ func NewTrackRepository(db *sql.DB) *TrackRepository {
 	return &TrackRepository{DB: db}
 }
 
-// TrackHabit records a habit tracking event
-func (r *TrackRepository) TrackHabit(record *HabitTrackRecord) error {
-	query := `
-        INSERT INTO habit_tracks (habit_id, date, completed, value, notes)
-        VALUES ($1, $2, $3, $4, $5)
-        ON CONFLICT (habit_id, date)
-        DO UPDATE SET completed = $3, value = $4, notes = $5
-        RETURNING id`
-
-	err := r.DB.QueryRow(
-		query,
-		record.HabitID,
-		record.Date,
-		record.Completed,
-		record.Value,
-		record.Notes,
-	).Scan(&record.ID)
-
-	return err
-}
-
 // GetTracking retrieves habit tracking records for a date range
 func (r *TrackRepository) GetTracking(habitID int64, startDate, endDate time.Time) ([]*HabitTrackRecord, error) {
 	query := `
-        SELECT id, habit_id, date, completed, value, notes
+        SELECT id, habit_id, date, completed, status, reason, value, notes
         FROM habit_tracks
         WHERE habit_id = $1 AND date >= $2 AND date <= $3
         ORDER BY date ASC`
@@ -318,6 +616,8 @@ func (r *TrackRepository) GetTracking(habitID int64, startDate, endDate time.Tim
 			&record.HabitID,
 			&record.Date,
 			&record.Completed,
+			&record.Status,
+			&record.Reason,
 			&record.Value,
 			&record.Notes,
 		)
@@ -334,6 +634,84 @@ func (r *TrackRepository) GetTracking(habitID int64, startDate, endDate time.Tim
 	return records, nil
 }
 
+// GetCompletedDates retrieves every date on which a habit was completed
+func (r *TrackRepository) GetCompletedDates(habitID int64) ([]time.Time, error) {
+	return r.getDatesByStatus(habitID, TrackCompleted)
+}
+
+// GetRelapseDates retrieves every date on which a habit to break was
+// relapsed, that is every tracked day marked missed
+func (r *TrackRepository) GetRelapseDates(habitID int64) ([]time.Time, error) {
+	return r.getDatesByStatus(habitID, TrackMissed)
+}
+
+// GetSkippedDates retrieves every date a habit was skipped on
+func (r *TrackRepository) GetSkippedDates(habitID int64) ([]time.Time, error) {
+	return r.getDatesByStatus(habitID, TrackSkipped)
+}
+
+// getDatesByStatus retrieves every date of a habit with the given status
+func (r *TrackRepository) getDatesByStatus(habitID int64, status string) ([]time.Time, error) {
+	query := `
+        SELECT date
+        FROM habit_tracks
+        WHERE habit_id = $1 AND status = $2
+        ORDER BY date ASC`
+
+	rows, err := r.DB.Query(query, habitID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := make([]time.Time, 0)
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return nil, err
+		}
+		days = append(days, date)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return days, nil
+}
+
+// GetLastRelapseTime retrieves when the latest check-in on a relapse day of
+// a habit to break was made, nil if it was never relapsed
+func (r *TrackRepository) GetLastRelapseTime(habitID int64) (*time.Time, error) {
+	query := `
+        SELECT MAX(e.occurred_at)
+        FROM habit_events e
+        JOIN habit_tracks t ON t.habit_id = e.habit_id AND t.date = e.date
+        WHERE e.habit_id = $1 AND t.status = 'missed'`
+
+	var last sql.NullTime
+	if err := r.DB.QueryRow(query, habitID).Scan(&last); err != nil {
+		return nil, err
+	}
+
+	if !last.Valid {
+		return nil, nil
+	}
+	return &last.Time, nil
+}
+
+// SumValues adds up the values tracked for a habit in a date range
+func (r *TrackRepository) SumValues(habitID int64, startDate, endDate time.Time) (float64, error) {
+	query := `
+        SELECT COALESCE(SUM(value), 0)
+        FROM habit_tracks
+        WHERE habit_id = $1 AND date >= $2 AND date <= $3`
+
+	var total float64
+	err := r.DB.QueryRow(query, habitID, startDate, endDate).Scan(&total)
+	return total, err
+}
+
 // StatRepository handles database operations for statistics
 type StatRepository struct {
 	DB *sql.DB
@@ -344,88 +722,304 @@ func NewStatRepository(db *sql.DB) *StatRepository {
 	return &StatRepository{DB: db}
 }
 
-// UpdateStats calculates and stores statistics for a habit
-func (r *StatRepository) UpdateStats(habitID int64, userID int64) (*Stat, error) {
-	// This is a simplified version - in a real app, this would be more sophisticated
-	// and would likely use database functions or a background worker
-	
-	// Get current time
+// UpdateStats refreshes the stored statistics of a habit after its tracking
+// changed, or once a day. A backfilled day can change any window and every
+// row's streaks, so all stored rows are dropped and the current window of
+// each period is calculated again. Streak freezes are settled first.
+func (r *StatRepository) UpdateStats(habitID int64, userID int64) error {
+	history, err := r.loadHistory(habitID, userID)
+	if err != nil {
+		return err
+	}
+
 	now := time.Now()
-	
-	// Calculate start and end dates for weekly stats (last 7 days)
-	endDate := now.Truncate(24 * time.Hour)
-	startDate := endDate.AddDate(0, 0, -6) // Last 7 days
-	
-	// Get habit tracking records for the date range
+	today := dates.In(now, history.User.Location())
+
+	// Settle freezes and replace the stored rows at once, so that a failed
+	// run can be retried without crediting freezes twice and readers never
+	// see a habit with only some of its rows
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Spend streak freezes on the misses that broke the habit's runs and
+	// earn new ones, before the stats show the outcome
+	firstDay, rule, completed := history.timeline(today)
+	history.Frozen, err = NewFreezeRepository(r.DB).Settle(tx, userID, habitID, func(frozen []time.Time) streak.Result {
+		rule.Frozen = streak.DateSet(frozen)
+		return streak.Compute(rule, completed, firstDay, today)
+	})
+	if err != nil {
+		return err
+	}
+
+	periods := []string{dates.Daily, dates.Weekly, dates.Monthly, dates.Yearly}
+	stats := make([]*Stat, 0, len(periods))
+	for _, period := range periods {
+		startDate, endDate := dates.PeriodBounds(period, today, history.User.WeekStart)
+		stat, err := r.calculate(history, period, startDate, endDate, now)
+		if err != nil {
+			return err
+		}
+		stats = append(stats, stat)
+	}
+
+	if _, err := tx.Exec("DELETE FROM habit_stats WHERE habit_id = $1", habitID); err != nil {
+		return err
+	}
+	for _, stat := range stats {
+		if err := storeStat(tx, stat); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Calculate calculates the statistics of a habit for the window from
+// startDate to endDate without storing them. Only the current window of each
+// period is stored, by UpdateStats.
+func (r *StatRepository) Calculate(habitID int64, userID int64, period string, startDate, endDate time.Time) (*Stat, error) {
+	history, err := r.loadHistory(habitID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.calculate(history, period, startDate, endDate, time.Now())
+}
+
+// habitHistory is what statistics are calculated from
+type habitHistory struct {
+	User          *User
+	Habit         *Habit
+	Pauses        []*Pause    // Pauses covering the habit
+	Completed     []time.Time // Dates a habit to build was completed on
+	Relapses      []time.Time // Dates a habit to break was relapsed on
+	Skipped       []time.Time // Dates the habit was excused on
+	Frozen        []time.Time // Dates, or first days of periods, a streak freeze covers
+	LastRelapseAt *time.Time  // Latest relapse check-in of a habit to break
+}
+
+// loadHistory loads the habit, its owner's calendar settings, its pauses,
+// the dates it was skipped on and the dates frozen, and the dates of its completions or, for a
+// habit to break, its relapses
+func (r *StatRepository) loadHistory(habitID int64, userID int64) (*habitHistory, error) {
+	user, err := NewUserRepository(r.DB).GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	habit, err := NewHabitRepository(r.DB).GetByID(habitID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pauses, err := NewPauseRepository(r.DB).GetByHabit(habitID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &habitHistory{User: user, Habit: habit, Pauses: pauses}
 	trackRepo := NewTrackRepository(r.DB)
-	records, err := trackRepo.GetTracking(habitID, startDate, endDate)
+
+	history.Skipped, err = trackRepo.GetSkippedDates(habitID)
+	if err != nil {
+		return nil, err
+	}
+
+	history.Frozen, err = NewFreezeRepository(r.DB).GetFrozenDatesByHabit(habitID)
+	if err != nil {
+		return nil, err
+	}
+
+	if habit.IsNegative() {
+		history.Relapses, err = trackRepo.GetRelapseDates(habitID)
+		if err != nil {
+			return nil, err
+		}
+		history.LastRelapseAt, err = trackRepo.GetLastRelapseTime(habitID)
+		if err != nil {
+			return nil, err
+		}
+		return history, nil
+	}
+
+	history.Completed, err = trackRepo.GetCompletedDates(habitID)
 	if err != nil {
 		return nil, err
 	}
-	
-	// Calculate statistics
-	totalDays := 7
+
+	return history, nil
+}
+
+// timeline returns the habit's first day, its rule and the dates up to
+// today it succeeded on, which for a habit to break are its clean days
+func (history *habitHistory) timeline(today time.Time) (time.Time, streak.Rule, []time.Time) {
+	habit := history.Habit
+
+	// The habit's history starts on its start date, or when it was created
+	// or earlier if days before that were backfilled
+	firstDay := habit.FirstDay(history.User.Location(), history.Completed, history.Relapses, history.Skipped)
+	rule := habit.Rule(history.User, history.Pauses, history.Skipped)
+	rule.Frozen = streak.DateSet(history.Frozen)
+
+	completed := history.Completed
+	if habit.IsNegative() {
+		completed = streak.CleanDays(rule, history.Relapses, firstDay, today)
+	}
+	return firstDay, rule, completed
+}
+
+// calculate computes the statistics of a window. Days before the habit was
+// started and days that haven't happened yet don't count towards total_days
+// or the expected occurrences, and neither do skipped days count towards the
+// latter. Streaks are as of the window's last elapsed day and carry on over
+// misses a streak freeze covers. A habit to break counts every day it is
+// expected on without a relapse as completed.
+func (r *StatRepository) calculate(history *habitHistory, period string, startDate, endDate time.Time, now time.Time) (*Stat, error) {
+	user, habit := history.User, history.Habit
+	today := dates.In(now, user.Location())
+	firstDay, rule, completed := history.timeline(today)
+
+	var daysClean *int
+	if habit.IsNegative() {
+		// Days since the latest relapse up to today, or since the start
+		lastDay := firstDay
+		for _, date := range history.Relapses {
+			if !date.After(today) {
+				lastDay = dates.Of(date)
+			}
+		}
+		days := dates.DaysBetween(lastDay, today) - 1
+		daysClean = &days
+	}
+
+	from := startDate
+	if firstDay.After(from) {
+		from = firstDay
+	}
+	to := endDate
+	if today.Before(to) {
+		to = today
+	}
+	if habit.EndDate != nil && habit.EndDate.Before(to) {
+		to = habit.EndDate.Time
+	}
+
+	totalDays := dates.DaysBetween(from, to)
 	completedDays := 0
-	streak := 0
-	longestStreak := 0
-	currentStreak := 0
-	
-	for _, record := range records {
-		if record.Completed {
+	for _, date := range completed {
+		if !date.Before(from) && !date.After(to) {
 			completedDays++
-			currentStreak++
-			if currentStreak > longestStreak {
-				longestStreak = currentStreak
-			}
-		} else {
-			if currentStreak > streak {
-				streak = currentStreak
-			}
-			currentStreak = 0
 		}
 	}
-	
-	// Set final streak
-	if currentStreak > streak {
-		streak = currentStreak
+	skippedDays := 0
+	for _, date := range history.Skipped {
+		if !date.Before(from) && !date.After(to) {
+			skippedDays++
+		}
+	}
+
+	// Success is measured against the occurrences the schedule expected, so
+	// unscheduled and skipped days are never misses
+	expected, met := streak.Expected(rule, completed, from, to)
+
+	successRate := 0.0
+	if expected > 0 {
+		successRate = float64(met) / float64(expected) * 100.0
 	}
-	
-	// Calculate success rate
-	successRate := float64(completedDays) / float64(totalDays) * 100.0
-	
-	// Create or update stat record
+
+	// Amounts over the same elapsed days, measured against the daily target.
+	// Day-based schedules average over their scheduled days only.
+	totalValue, averageValue, targetPercent := 0.0, 0.0, 0.0
+	if totalDays > 0 {
+		var err error
+		totalValue, err = NewTrackRepository(r.DB).SumValues(habit.ID, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		valueDays := totalDays
+		if rule.IsDayBased() {
+			valueDays = expected
+		}
+		if valueDays > 0 {
+			averageValue = totalValue / float64(valueDays)
+		}
+		if habit.IsQuantitative() {
+			targetPercent = averageValue / habit.TargetValue * 100.0
+		}
+	}
+
+	streaks := streak.Compute(rule, completed, firstDay, to)
+
 	stat := &Stat{
-		UserID:       userID,
-		HabitID:      habitID,
-		Period:       "weekly",
-		StartDate:    startDate,
-		EndDate:      endDate,
-		TotalDays:    totalDays,
-		CompletedDays: completedDays,
-		SuccessRate:  successRate,
-		Streak:       streak,
-		LongestStreak: longestStreak,
-		CalculatedAt: now,
-	}
-	
-	// Store in database
+		UserID:             user.ID,
+		HabitID:            habit.ID,
+		Period:             period,
+		StartDate:          startDate,
+		EndDate:            endDate,
+		TotalDays:          totalDays,
+		CompletedDays:      completedDays,
+		ExpectedCount:      expected,
+		MetCount:           met,
+		SuccessRate:        successRate,
+		SkippedDays:        skippedDays,
+		Streak:             streaks.Current.Length,
+		LongestStreak:      streaks.Longest.Length,
+		StreakUnit:         streaks.Unit,
+		StreakStart:        streaks.Current.StartDate,
+		StreakEnd:          streaks.Current.EndDate,
+		LongestStreakStart: streaks.Longest.StartDate,
+		LongestStreakEnd:   streaks.Longest.EndDate,
+		DaysClean:          daysClean,
+		LastRelapseAt:      history.LastRelapseAt,
+		TotalValue:         totalValue,
+		AverageValue:       averageValue,
+		TargetPercent:      targetPercent,
+		CalculatedAt:       now,
+	}
+
+	return stat, nil
+}
+
+// storeStat saves the statistics of a window, replacing any stored for it
+func storeStat(tx *sql.Tx, stat *Stat) error {
 	query := `
         INSERT INTO habit_stats (
             user_id, habit_id, period, start_date, end_date,
-            total_days, completed_days, success_rate, streak, longest_streak, calculated_at
+            total_days, completed_days, success_rate, streak, longest_streak, calculated_at,
+            streak_unit, streak_start, streak_end, longest_streak_start, longest_streak_end,
+            total_value, average_value, target_percent, expected_count, met_count,
+            days_clean, last_relapse_at, skipped_days
         )
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)
         ON CONFLICT (habit_id, period, start_date, end_date)
-        DO UPDATE SET 
-            total_days = $6, 
-            completed_days = $7, 
-            success_rate = $8, 
-            streak = $9, 
+        DO UPDATE SET
+            total_days = $6,
+            completed_days = $7,
+            success_rate = $8,
+            streak = $9,
             longest_streak = $10,
-            calculated_at = $11
+            calculated_at = $11,
+            streak_unit = $12,
+            streak_start = $13,
+            streak_end = $14,
+            longest_streak_start = $15,
+            longest_streak_end = $16,
+            total_value = $17,
+            average_value = $18,
+            target_percent = $19,
+            expected_count = $20,
+            met_count = $21,
+            days_clean = $22,
+            last_relapse_at = $23,
+            skipped_days = $24
         RETURNING id`
-	
-	err = r.DB.QueryRow(
+
+	return tx.QueryRow(
 		query,
 		stat.UserID,
 		stat.HabitID,
@@ -438,28 +1032,38 @@ func (r *StatRepository) UpdateStats(habitID int64, userID int64) (*Stat, error)
 		stat.Streak,
 		stat.LongestStreak,
 		stat.CalculatedAt,
+		stat.StreakUnit,
+		stat.StreakStart,
+		stat.StreakEnd,
+		stat.LongestStreakStart,
+		stat.LongestStreakEnd,
+		stat.TotalValue,
+		stat.AverageValue,
+		stat.TargetPercent,
+		stat.ExpectedCount,
+		stat.MetCount,
+		stat.DaysClean,
+		stat.LastRelapseAt,
+		stat.SkippedDays,
 	).Scan(&stat.ID)
-	
-	if err != nil {
-		return nil, err
-	}
-	
-	return stat, nil
 }
 
-// GetStats retrieves statistics for a habit
-func (r *StatRepository) GetStats(habitID int64, userID int64, period string) (*Stat, error) {
+// GetStats retrieves the stored statistics of a habit for a window
+func (r *StatRepository) GetStats(habitID int64, userID int64, period string, startDate, endDate time.Time) (*Stat, error) {
 	stat := &Stat{}
+	var streakStart, streakEnd, longestStart, longestEnd, lastRelapseAt sql.NullTime
+	var daysClean sql.NullInt64
 	query := `
-        SELECT 
+        SELECT
             id, user_id, habit_id, period, start_date, end_date,
-            total_days, completed_days, success_rate, streak, longest_streak, calculated_at
+            total_days, completed_days, success_rate, streak, longest_streak, calculated_at,
+            streak_unit, streak_start, streak_end, longest_streak_start, longest_streak_end,
+            total_value, average_value, target_percent, expected_count, met_count,
+            days_clean, last_relapse_at, skipped_days
         FROM habit_stats
-        WHERE habit_id = $1 AND user_id = $2 AND period = $3
-        ORDER BY calculated_at DESC
-        LIMIT 1`
-	
-	err := r.DB.QueryRow(query, habitID, userID, period).Scan(
+        WHERE habit_id = $1 AND user_id = $2 AND period = $3 AND start_date = $4 AND end_date = $5`
+
+	err := r.DB.QueryRow(query, habitID, userID, period, startDate, endDate).Scan(
 		&stat.ID,
 		&stat.UserID,
 		&stat.HabitID,
@@ -472,14 +1076,47 @@ func (r *StatRepository) GetStats(habitID int64, userID int64, period string) (*
 		&stat.Streak,
 		&stat.LongestStreak,
 		&stat.CalculatedAt,
+		&stat.StreakUnit,
+		&streakStart,
+		&streakEnd,
+		&longestStart,
+		&longestEnd,
+		&stat.TotalValue,
+		&stat.AverageValue,
+		&stat.TargetPercent,
+		&stat.ExpectedCount,
+		&stat.MetCount,
+		&daysClean,
+		&lastRelapseAt,
+		&stat.SkippedDays,
 	)
-	
+
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("stats not found")
 		}
 		return nil, err
 	}
-	
+
+	if streakStart.Valid {
+		stat.StreakStart = &streakStart.Time
+	}
+	if streakEnd.Valid {
+		stat.StreakEnd = &streakEnd.Time
+	}
+	if longestStart.Valid {
+		stat.LongestStreakStart = &longestStart.Time
+	}
+	if longestEnd.Valid {
+		stat.LongestStreakEnd = &longestEnd.Time
+	}
+	if daysClean.Valid {
+		days := int(daysClean.Int64)
+		stat.DaysClean = &days
+	}
+	if lastRelapseAt.Valid {
+		stat.LastRelapseAt = &lastRelapseAt.Time
+	}
+
 	return stat, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DayCompletion is the number of habits completed on a day
+type DayCompletion struct {
+	Date      time.Time
+	Completed int
+}
+
+// HeatmapRepository runs the aggregate queries behind the heatmaps
+type HeatmapRepository struct {
+	DB *sql.DB
+}
+
+// NewHeatmapRepository creates a new heatmap repository
+func NewHeatmapRepository(db *sql.DB) *HeatmapRepository {
+	return &HeatmapRepository{DB: db}
+}
+
+// GetDailyCompletions counts, for each day in a date range, how many of a
+// user's active habits to build were completed. Days without completions
+// are omitted.
+func (r *HeatmapRepository) GetDailyCompletions(userID int64, startDate, endDate time.Time) ([]*DayCompletion, error) {
+	query := `
+        SELECT t.date, COUNT(*)
+        FROM habit_tracks t
+        JOIN habits h ON h.id = t.habit_id
+        WHERE h.user_id = $1 AND h.is_archived = false AND h.type = 'positive'
+          AND t.completed = true AND t.date >= $2 AND t.date <= $3
+        GROUP BY t.date
+        ORDER BY t.date ASC`
+
+	rows, err := r.DB.Query(query, userID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := make([]*DayCompletion, 0)
+	for rows.Next() {
+		day := &DayCompletion{}
+		if err := rows.Scan(&day.Date, &day.Completed); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return days, nil
+}
+
+// GetRelapseDates retrieves the dates in a date range on which each active
+// habit to break of a user was relapsed, keyed by habit ID
+func (r *HeatmapRepository) GetRelapseDates(userID int64, startDate, endDate time.Time) (map[int64][]time.Time, error) {
+	query := `
+        SELECT t.habit_id, t.date
+        FROM habit_tracks t
+        JOIN habits h ON h.id = t.habit_id
+        WHERE h.user_id = $1 AND h.is_archived = false AND h.type = 'negative'
+          AND t.status = 'missed' AND t.date >= $2 AND t.date <= $3
+        ORDER BY t.habit_id, t.date ASC`
+
+	return queryHabitDates(r.DB, query, userID, startDate, endDate)
+}
+
+// GetSkippedDates retrieves the dates in a date range on which each active
+// habit of a user was skipped, keyed by habit ID
+func (r *HeatmapRepository) GetSkippedDates(userID int64, startDate, endDate time.Time) (map[int64][]time.Time, error) {
+	query := `
+        SELECT t.habit_id, t.date
+        FROM habit_tracks t
+        JOIN habits h ON h.id = t.habit_id
+        WHERE h.user_id = $1 AND h.is_archived = false
+          AND t.status = 'skipped' AND t.date >= $2 AND t.date <= $3
+        ORDER BY t.habit_id, t.date ASC`
+
+	return queryHabitDates(r.DB, query, userID, startDate, endDate)
+}
+
+// GetFirstTrackedDates retrieves the earliest tracked date of each of a
+// user's active habits, keyed by habit ID
+func (r *HeatmapRepository) GetFirstTrackedDates(userID int64) (map[int64]time.Time, error) {
+	query := `
+        SELECT t.habit_id, MIN(t.date)
+        FROM habit_tracks t
+        JOIN habits h ON h.id = t.habit_id
+        WHERE h.user_id = $1 AND h.is_archived = false
+        GROUP BY t.habit_id`
+
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	first := make(map[int64]time.Time)
+	for rows.Next() {
+		var habitID int64
+		var date time.Time
+		if err := rows.Scan(&habitID, &date); err != nil {
+			return nil, err
+		}
+		first[habitID] = date
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return first, nil
+}
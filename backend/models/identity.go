@@ -0,0 +1,148 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// UserIdentity model linking an external OpenID Connect account to a user
+type UserIdentity struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCLoginState model for an authorization request awaiting its callback
+type OIDCLoginState struct {
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// IdentityRepository handles database operations for external identities
+type IdentityRepository struct {
+	DB *sql.DB
+}
+
+// NewIdentityRepository creates a new identity repository
+func NewIdentityRepository(db *sql.DB) *IdentityRepository {
+	return &IdentityRepository{DB: db}
+}
+
+// Create links an external identity to a user
+func (r *IdentityRepository) Create(identity *UserIdentity) error {
+	identity.CreatedAt = time.Now()
+
+	query := `
+        INSERT INTO user_identities (user_id, provider, subject, email, created_at, last_login)
+        VALUES ($1, $2, $3, $4, $5, $5)
+        RETURNING id`
+
+	err := r.DB.QueryRow(
+		query,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+		identity.CreatedAt,
+	).Scan(&identity.ID)
+
+	return err
+}
+
+// GetBySubject retrieves the identity for a provider account
+func (r *IdentityRepository) GetBySubject(provider string, subject string) (*UserIdentity, error) {
+	identity := &UserIdentity{}
+	var email sql.NullString
+	query := `
+        SELECT id, user_id, provider, subject, email, created_at
+        FROM user_identities
+        WHERE provider = $1 AND subject = $2`
+
+	err := r.DB.QueryRow(query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&email,
+		&identity.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("identity not found")
+		}
+		return nil, err
+	}
+
+	identity.Email = email.String
+	return identity, nil
+}
+
+// UpdateLastLogin records a sign-in through an identity
+func (r *IdentityRepository) UpdateLastLogin(id int64) error {
+	query := `
+        UPDATE user_identities
+        SET last_login = $1
+        WHERE id = $2`
+
+	_, err := r.DB.Exec(query, time.Now(), id)
+	return err
+}
+
+// SaveLoginState stores a pending authorization request
+func (r *IdentityRepository) SaveLoginState(state *OIDCLoginState) error {
+	query := `
+        INSERT INTO oidc_login_states (state_hash, provider, nonce, code_verifier, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.DB.Exec(
+		query,
+		state.StateHash,
+		state.Provider,
+		state.Nonce,
+		state.CodeVerifier,
+		time.Now(),
+		state.ExpiresAt,
+	)
+
+	return err
+}
+
+// ConsumeLoginState retrieves and deletes a pending authorization request so
+// that a state value can only be used once. Expired rows are cleaned up too.
+func (r *IdentityRepository) ConsumeLoginState(stateHash string, provider string) (*OIDCLoginState, error) {
+	now := time.Now()
+	if _, err := r.DB.Exec(`DELETE FROM oidc_login_states WHERE expires_at <= $1`, now); err != nil {
+		return nil, err
+	}
+
+	state := &OIDCLoginState{}
+	query := `
+        DELETE FROM oidc_login_states
+        WHERE state_hash = $1 AND provider = $2
+        RETURNING state_hash, provider, nonce, code_verifier, expires_at`
+
+	err := r.DB.QueryRow(query, stateHash, provider).Scan(
+		&state.StateHash,
+		&state.Provider,
+		&state.Nonce,
+		&state.CodeVerifier,
+		&state.ExpiresAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("invalid or expired login state")
+		}
+		return nil, err
+	}
+
+	return state, nil
+}
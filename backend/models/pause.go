@@ -0,0 +1,182 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+)
+
+// ErrPauseNotFound is returned when a pause doesn't exist for the user
+var ErrPauseNotFound = errors.New("pause not found")
+
+// Pause model for periods a habit, or with vacation mode every habit of a
+// user, is not expected on. Paused days are neither successes nor misses.
+type Pause struct {
+	ID        int64       `json:"id"`
+	UserID    int64       `json:"user_id"`
+	HabitID   *int64      `json:"habit_id"` // Nil for account-wide vacation mode
+	StartDate dates.Date  `json:"start_date"`
+	EndDate   *dates.Date `json:"end_date"` // Nil while the pause is open-ended
+	Reason    string      `json:"reason"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// AppliesTo reports whether the pause covers a habit
+func (p *Pause) AppliesTo(habitID int64) bool {
+	return p.HabitID == nil || *p.HabitID == habitID
+}
+
+// PauseRepository handles database operations for pauses
+type PauseRepository struct {
+	DB *sql.DB
+}
+
+// NewPauseRepository creates a new pause repository
+func NewPauseRepository(db *sql.DB) *PauseRepository {
+	return &PauseRepository{DB: db}
+}
+
+// Create inserts a new pause in the database
+func (r *PauseRepository) Create(pause *Pause) error {
+	pause.CreatedAt = time.Now()
+
+	query := `
+        INSERT INTO habit_pauses (user_id, habit_id, start_date, end_date, reason, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id`
+
+	return r.DB.QueryRow(
+		query,
+		pause.UserID,
+		pause.HabitID,
+		pause.StartDate,
+		pause.EndDate,
+		pause.Reason,
+		pause.CreatedAt,
+	).Scan(&pause.ID)
+}
+
+// GetByID retrieves a pause by ID and user ID (for security)
+func (r *PauseRepository) GetByID(id int64, userID int64) (*Pause, error) {
+	query := `
+        SELECT id, user_id, habit_id, start_date, end_date, reason, created_at
+        FROM habit_pauses
+        WHERE id = $1 AND user_id = $2`
+
+	pauses, err := r.query(query, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pauses) == 0 {
+		return nil, ErrPauseNotFound
+	}
+	return pauses[0], nil
+}
+
+// GetAllByUser retrieves every pause of a user, per habit and account-wide,
+// latest first
+func (r *PauseRepository) GetAllByUser(userID int64) ([]*Pause, error) {
+	query := `
+        SELECT id, user_id, habit_id, start_date, end_date, reason, created_at
+        FROM habit_pauses
+        WHERE user_id = $1
+        ORDER BY start_date DESC, id DESC`
+
+	return r.query(query, userID)
+}
+
+// GetByHabit retrieves the pauses covering a habit, including account-wide
+// ones, latest first
+func (r *PauseRepository) GetByHabit(habitID int64, userID int64) ([]*Pause, error) {
+	query := `
+        SELECT id, user_id, habit_id, start_date, end_date, reason, created_at
+        FROM habit_pauses
+        WHERE user_id = $1 AND (habit_id = $2 OR habit_id IS NULL)
+        ORDER BY start_date DESC, id DESC`
+
+	return r.query(query, userID, habitID)
+}
+
+// SetEndDate closes a pause on the given day
+func (r *PauseRepository) SetEndDate(id int64, userID int64, endDate dates.Date) error {
+	result, err := r.DB.Exec("UPDATE habit_pauses SET end_date = $1 WHERE id = $2 AND user_id = $3", endDate, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrPauseNotFound
+	}
+	return nil
+}
+
+// Delete removes a pause
+func (r *PauseRepository) Delete(id int64, userID int64) error {
+	result, err := r.DB.Exec("DELETE FROM habit_pauses WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrPauseNotFound
+	}
+	return nil
+}
+
+// query runs a query selecting pause columns
+func (r *PauseRepository) query(query string, args ...any) ([]*Pause, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pauses := make([]*Pause, 0)
+	for rows.Next() {
+		pause := &Pause{}
+		var habitID sql.NullInt64
+		var startDate time.Time
+		var endDate sql.NullTime
+		err := rows.Scan(
+			&pause.ID,
+			&pause.UserID,
+			&habitID,
+			&startDate,
+			&endDate,
+			&pause.Reason,
+			&pause.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		pause.StartDate = dates.NewDate(startDate)
+		if habitID.Valid {
+			pause.HabitID = &habitID.Int64
+		}
+		if endDate.Valid {
+			end := dates.NewDate(endDate.Time)
+			pause.EndDate = &end
+		}
+		pauses = append(pauses, pause)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pauses, nil
+}
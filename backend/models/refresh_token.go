@@ -0,0 +1,144 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// RefreshToken model for server-side refresh token storage.
+// Only the SHA-256 hash of the token is stored. Tokens issued from the same
+// login share a family ID so that the whole chain can be revoked at once.
+type RefreshToken struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	TokenHash string       `json:"-"`
+	FamilyID  string       `json:"family_id"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"-"`
+	RevokedAt sql.NullTime `json:"-"`
+}
+
+// IsUsed reports whether the token has already been exchanged
+func (t *RefreshToken) IsUsed() bool {
+	return t.UsedAt.Valid
+}
+
+// IsRevoked reports whether the token has been revoked
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt.Valid
+}
+
+// IsExpired reports whether the token is past its expiry time
+func (t *RefreshToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// RefreshTokenRepository handles database operations for refresh tokens
+type RefreshTokenRepository struct {
+	DB *sql.DB
+}
+
+// NewRefreshTokenRepository creates a new refresh token repository
+func NewRefreshTokenRepository(db *sql.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{DB: db}
+}
+
+// Create inserts a new refresh token in the database
+func (r *RefreshTokenRepository) Create(token *RefreshToken) error {
+	token.CreatedAt = time.Now()
+
+	query := `
+        INSERT INTO refresh_tokens (user_id, token_hash, family_id, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id`
+
+	err := r.DB.QueryRow(
+		query,
+		token.UserID,
+		token.TokenHash,
+		token.FamilyID,
+		token.CreatedAt,
+		token.ExpiresAt,
+	).Scan(&token.ID)
+
+	return err
+}
+
+// GetByHash retrieves a refresh token by its hash
+func (r *RefreshTokenRepository) GetByHash(tokenHash string) (*RefreshToken, error) {
+	token := &RefreshToken{}
+	query := `
+        SELECT id, user_id, token_hash, family_id, created_at, expires_at, used_at, revoked_at
+        FROM refresh_tokens
+        WHERE token_hash = $1`
+
+	err := r.DB.QueryRow(query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.FamilyID,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RevokedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("refresh token not found")
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// MarkUsed flags a refresh token as exchanged. It fails if the token was
+// already used or revoked, which guards against two concurrent refreshes
+// both succeeding with the same token.
+func (r *RefreshTokenRepository) MarkUsed(id int64) error {
+	query := `
+        UPDATE refresh_tokens
+        SET used_at = $1
+        WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL`
+
+	result, err := r.DB.Exec(query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("refresh token already used or revoked")
+	}
+
+	return nil
+}
+
+// RevokeFamily revokes every token that belongs to a token family
+func (r *RefreshTokenRepository) RevokeFamily(familyID string) error {
+	query := `
+        UPDATE refresh_tokens
+        SET revoked_at = $1
+        WHERE family_id = $2 AND revoked_at IS NULL`
+
+	_, err := r.DB.Exec(query, time.Now(), familyID)
+	return err
+}
+
+// RevokeAllForUser revokes every refresh token issued to a user
+func (r *RefreshTokenRepository) RevokeAllForUser(userID int64) error {
+	query := `
+        UPDATE refresh_tokens
+        SET revoked_at = $1
+        WHERE user_id = $2 AND revoked_at IS NULL`
+
+	_, err := r.DB.Exec(query, time.Now(), userID)
+	return err
+}
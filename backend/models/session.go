@@ -0,0 +1,243 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Session model for a signed-in device.
+// Each session owns one refresh token family.
+type Session struct {
+	ID         int64        `json:"id"`
+	UserID     int64        `json:"user_id"`
+	FamilyID   string       `json:"-"`
+	DeviceName string       `json:"device_name"`
+	IPAddress  string       `json:"ip_address"`
+	UserAgent  string       `json:"user_agent"`
+	CreatedAt  time.Time    `json:"created_at"`
+	LastSeenAt time.Time    `json:"last_seen_at"`
+	RevokedAt  sql.NullTime `json:"-"`
+	Current    bool         `json:"current"` // Set by handlers for the requesting session
+}
+
+// IsRevoked reports whether the session has been ended
+func (s *Session) IsRevoked() bool {
+	return s.RevokedAt.Valid
+}
+
+// SessionRepository handles database operations for sessions
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+// NewSessionRepository creates a new session repository
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{DB: db}
+}
+
+// Create inserts a new session in the database
+func (r *SessionRepository) Create(session *Session) error {
+	now := time.Now()
+	session.CreatedAt = now
+	session.LastSeenAt = now
+
+	query := `
+        INSERT INTO sessions (
+            user_id, family_id, device_name, ip_address, user_agent, created_at, last_seen_at
+        )
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id`
+
+	err := r.DB.QueryRow(
+		query,
+		session.UserID,
+		session.FamilyID,
+		session.DeviceName,
+		session.IPAddress,
+		session.UserAgent,
+		session.CreatedAt,
+		session.LastSeenAt,
+	).Scan(&session.ID)
+
+	return err
+}
+
+// GetByID retrieves a session by ID
+func (r *SessionRepository) GetByID(id int64) (*Session, error) {
+	query := `
+        SELECT id, user_id, family_id, device_name, ip_address, user_agent,
+            created_at, last_seen_at, revoked_at
+        FROM sessions
+        WHERE id = $1`
+
+	return r.scanOne(r.DB.QueryRow(query, id))
+}
+
+// GetByFamilyID retrieves the session that owns a refresh token family
+func (r *SessionRepository) GetByFamilyID(familyID string) (*Session, error) {
+	query := `
+        SELECT id, user_id, family_id, device_name, ip_address, user_agent,
+            created_at, last_seen_at, revoked_at
+        FROM sessions
+        WHERE family_id = $1`
+
+	return r.scanOne(r.DB.QueryRow(query, familyID))
+}
+
+// GetActiveByUser retrieves all sessions of a user that have not been revoked
+func (r *SessionRepository) GetActiveByUser(userID int64) ([]*Session, error) {
+	query := `
+        SELECT id, user_id, family_id, device_name, ip_address, user_agent,
+            created_at, last_seen_at, revoked_at
+        FROM sessions
+        WHERE user_id = $1 AND revoked_at IS NULL
+        ORDER BY last_seen_at DESC`
+
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*Session, 0)
+	for rows.Next() {
+		session := &Session{}
+		var deviceName, ipAddress, userAgent sql.NullString
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.FamilyID,
+			&deviceName,
+			&ipAddress,
+			&userAgent,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+			&session.RevokedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		session.DeviceName = deviceName.String
+		session.IPAddress = ipAddress.String
+		session.UserAgent = userAgent.String
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Touch records activity on a session. Writes are throttled to once a minute
+// so that authenticated requests don't each cause an update.
+func (r *SessionRepository) Touch(id int64, ipAddress string) error {
+	now := time.Now()
+	query := `
+        UPDATE sessions
+        SET last_seen_at = $1, ip_address = $2
+        WHERE id = $3 AND last_seen_at < $4`
+
+	_, err := r.DB.Exec(query, now, ipAddress, id, now.Add(-time.Minute))
+	return err
+}
+
+// Revoke ends a session belonging to a user and revokes its refresh tokens
+func (r *SessionRepository) Revoke(id int64, userID int64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var familyID string
+	query := `
+        UPDATE sessions
+        SET revoked_at = $1
+        WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+        RETURNING family_id`
+
+	err = tx.QueryRow(query, now, id, userID).Scan(&familyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("session not found or already revoked")
+		}
+		return err
+	}
+
+	query = `
+        UPDATE refresh_tokens
+        SET revoked_at = $1
+        WHERE family_id = $2 AND revoked_at IS NULL`
+
+	if _, err := tx.Exec(query, now, familyID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RevokeAllForUser ends every session of a user and revokes their refresh
+// tokens. A non-zero exceptID keeps that session alive.
+func (r *SessionRepository) RevokeAllForUser(userID int64, exceptID int64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	query := `
+        UPDATE sessions
+        SET revoked_at = $1
+        WHERE user_id = $2 AND id <> $3 AND revoked_at IS NULL`
+
+	if _, err := tx.Exec(query, now, userID, exceptID); err != nil {
+		return err
+	}
+
+	query = `
+        UPDATE refresh_tokens
+        SET revoked_at = $1
+        WHERE user_id = $2 AND revoked_at IS NULL
+          AND family_id NOT IN (SELECT family_id FROM sessions WHERE id = $3)`
+
+	if _, err := tx.Exec(query, now, userID, exceptID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// scanOne scans a single session row
+func (r *SessionRepository) scanOne(row *sql.Row) (*Session, error) {
+	session := &Session{}
+	var deviceName, ipAddress, userAgent sql.NullString
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.FamilyID,
+		&deviceName,
+		&ipAddress,
+		&userAgent,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.RevokedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("session not found")
+		}
+		return nil, err
+	}
+
+	session.DeviceName = deviceName.String
+	session.IPAddress = ipAddress.String
+	session.UserAgent = userAgent.String
+
+	return session, nil
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	// statsJobDebounce delays a queued recalculation so that a burst of
+	// check-ins on one habit results in a single run
+	statsJobDebounce = 5 * time.Second
+	// statsJobMaxDelay bounds how far repeated check-ins can push a job back
+	statsJobMaxDelay = time.Minute
+)
+
+// StatsJob is a pending recalculation of a habit's statistics
+type StatsJob struct {
+	HabitID  int64
+	UserID   int64
+	RunAt    time.Time
+	Attempts int
+}
+
+// StatsJobRepository handles the statistics job queue. Jobs are claimed
+// with row locks skipped by other workers, so any number of instances can
+// process the queue concurrently.
+type StatsJobRepository struct {
+	DB *sql.DB
+}
+
+// NewStatsJobRepository creates a new stats job repository
+func NewStatsJobRepository(db *sql.DB) *StatsJobRepository {
+	return &StatsJobRepository{DB: db}
+}
+
+// Enqueue schedules a recalculation of a habit's statistics. A job already
+// queued for the habit is pushed back by the debounce delay instead, up to
+// statsJobMaxDelay after it was first queued. A failed job gets a fresh set
+// of attempts, since the change may have fixed it.
+func (r *StatsJobRepository) Enqueue(habitID int64, userID int64) error {
+	now := time.Now()
+	query := `
+        INSERT INTO stats_jobs (habit_id, user_id, queued_at, run_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (habit_id) DO UPDATE SET
+            run_at = LEAST(EXCLUDED.run_at, stats_jobs.queued_at + $5 * INTERVAL '1 second'),
+            attempts = 0,
+            last_error = NULL,
+            status = 'pending'`
+
+	_, err := r.DB.Exec(query, habitID, userID, now, now.Add(statsJobDebounce), statsJobMaxDelay.Seconds())
+	return err
+}
+
+// EnqueueUser queues a recalculation of every active habit of a user, after
+// a change affecting all of them
+func (r *StatsJobRepository) EnqueueUser(userID int64) error {
+	now := time.Now()
+	query := `
+        INSERT INTO stats_jobs (habit_id, user_id, queued_at, run_at)
+        SELECT id, user_id, $2, $3
+        FROM habits
+        WHERE user_id = $1 AND is_archived = false
+        ON CONFLICT (habit_id) DO UPDATE SET
+            run_at = LEAST(EXCLUDED.run_at, stats_jobs.queued_at + $4 * INTERVAL '1 second'),
+            attempts = 0,
+            last_error = NULL,
+            status = 'pending'`
+
+	_, err := r.DB.Exec(query, userID, now, now.Add(statsJobDebounce), statsJobMaxDelay.Seconds())
+	return err
+}
+
+// EnqueueDailyRefresh queues every active habit of the users for whom a new
+// day has begun in their time zone since their last refresh. Returns the
+// number of jobs queued.
+func (r *StatsJobRepository) EnqueueDailyRefresh(now time.Time) (int64, error) {
+	query := `
+        WITH due AS (
+            UPDATE users
+            SET stats_refreshed_on = ($1::timestamptz AT TIME ZONE timezone)::date
+            WHERE stats_refreshed_on IS NULL
+               OR stats_refreshed_on < ($1::timestamptz AT TIME ZONE timezone)::date
+            RETURNING id
+        )
+        INSERT INTO stats_jobs (habit_id, user_id, queued_at, run_at)
+        SELECT h.id, h.user_id, $2, $2
+        FROM habits h
+        JOIN due ON due.id = h.user_id
+        WHERE h.is_archived = false
+        ON CONFLICT (habit_id) DO NOTHING`
+
+	result, err := r.DB.Exec(query, now, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// IsQueued reports whether a recalculation of the habit's statistics is
+// queued or has failed, meaning its stored statistics may be out of date
+func (r *StatsJobRepository) IsQueued(habitID int64) (bool, error) {
+	var queued bool
+	err := r.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM stats_jobs WHERE habit_id = $1)", habitID).Scan(&queued)
+	return queued, err
+}
+
+// Claim locks up to limit due jobs for lease and returns them
+func (r *StatsJobRepository) Claim(now time.Time, lease time.Duration, limit int) ([]*StatsJob, error) {
+	query := `
+        UPDATE stats_jobs
+        SET locked_until = $2
+        WHERE habit_id IN (
+            SELECT habit_id
+            FROM stats_jobs
+            WHERE status = 'pending' AND run_at <= $1 AND (locked_until IS NULL OR locked_until < $1)
+            ORDER BY run_at ASC
+            LIMIT $3
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING habit_id, user_id, run_at, attempts`
+
+	rows, err := r.DB.Query(query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]*StatsJob, 0)
+	for rows.Next() {
+		job := &StatsJob{}
+		if err := rows.Scan(&job.HabitID, &job.UserID, &job.RunAt, &job.Attempts); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// Complete removes a processed job. If the habit was queued again while the
+// job ran, the job is kept and unlocked so that it runs once more.
+func (r *StatsJobRepository) Complete(job *StatsJob) error {
+	result, err := r.DB.Exec("DELETE FROM stats_jobs WHERE habit_id = $1 AND run_at = $2", job.HabitID, job.RunAt)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		_, err = r.DB.Exec("UPDATE stats_jobs SET locked_until = NULL WHERE habit_id = $1", job.HabitID)
+	}
+	return err
+}
+
+// Retry unlocks a failed job and schedules its next attempt
+func (r *StatsJobRepository) Retry(job *StatsJob, runAt time.Time, lastError string) error {
+	query := `
+        UPDATE stats_jobs
+        SET run_at = $1, attempts = attempts + 1, last_error = $2, locked_until = NULL
+        WHERE habit_id = $3 AND run_at = $4`
+
+	result, err := r.DB.Exec(query, runAt, lastError, job.HabitID, job.RunAt)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// Queued again while running; the new run takes the place of a retry
+	if rowsAffected == 0 {
+		_, err = r.DB.Exec("UPDATE stats_jobs SET locked_until = NULL WHERE habit_id = $1", job.HabitID)
+	}
+	return err
+}
+
+// Fail unlocks a job that used up its attempts and sets it aside. It stays
+// failed until the habit is queued again.
+func (r *StatsJobRepository) Fail(job *StatsJob, lastError string) error {
+	query := `
+        UPDATE stats_jobs
+        SET status = 'failed', attempts = attempts + 1, last_error = $1, locked_until = NULL
+        WHERE habit_id = $2 AND run_at = $3`
+
+	result, err := r.DB.Exec(query, lastError, job.HabitID, job.RunAt)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// Queued again while running; the new run gets its own attempts
+	if rowsAffected == 0 {
+		_, err = r.DB.Exec("UPDATE stats_jobs SET locked_until = NULL WHERE habit_id = $1", job.HabitID)
+	}
+	return err
+}
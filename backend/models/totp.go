@@ -0,0 +1,230 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// TOTPSettings model for a user's TOTP two-factor configuration
+type TOTPSettings struct {
+	UserID       int64        `json:"user_id"`
+	Secret       string       `json:"-"`
+	CreatedAt    time.Time    `json:"created_at"`
+	ConfirmedAt  sql.NullTime `json:"-"`
+	LastUsedStep int64        `json:"-"`
+}
+
+// IsEnabled reports whether enrollment has been confirmed
+func (t *TOTPSettings) IsEnabled() bool {
+	return t.ConfirmedAt.Valid
+}
+
+// TOTPRepository handles database operations for two-factor authentication
+type TOTPRepository struct {
+	DB *sql.DB
+}
+
+// NewTOTPRepository creates a new TOTP repository
+func NewTOTPRepository(db *sql.DB) *TOTPRepository {
+	return &TOTPRepository{DB: db}
+}
+
+// GetByUserID retrieves the TOTP settings of a user
+func (r *TOTPRepository) GetByUserID(userID int64) (*TOTPSettings, error) {
+	settings := &TOTPSettings{}
+	query := `
+        SELECT user_id, secret, created_at, confirmed_at, last_used_step
+        FROM user_totp
+        WHERE user_id = $1`
+
+	err := r.DB.QueryRow(query, userID).Scan(
+		&settings.UserID,
+		&settings.Secret,
+		&settings.CreatedAt,
+		&settings.ConfirmedAt,
+		&settings.LastUsedStep,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("two-factor authentication not configured")
+		}
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// IsEnabled reports whether a user has confirmed two-factor authentication
+func (r *TOTPRepository) IsEnabled(userID int64) (bool, error) {
+	var enabled bool
+	query := `
+        SELECT EXISTS (
+            SELECT 1 FROM user_totp WHERE user_id = $1 AND confirmed_at IS NOT NULL
+        )`
+
+	err := r.DB.QueryRow(query, userID).Scan(&enabled)
+	return enabled, err
+}
+
+// SavePending stores a new unconfirmed secret, replacing any earlier
+// unconfirmed enrollment. It fails if two-factor is already enabled.
+func (r *TOTPRepository) SavePending(userID int64, secret string) error {
+	query := `
+        INSERT INTO user_totp (user_id, secret, created_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id)
+        DO UPDATE SET secret = $2, created_at = $3, last_used_step = 0
+        WHERE user_totp.confirmed_at IS NULL`
+
+	result, err := r.DB.Exec(query, userID, secret, time.Now())
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("two-factor authentication already enabled")
+	}
+
+	return nil
+}
+
+// Confirm enables two-factor authentication and replaces the user's
+// recovery codes with the given hashes
+func (r *TOTPRepository) Confirm(userID int64, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        UPDATE user_totp
+        SET confirmed_at = $1, last_used_step = $2
+        WHERE user_id = $3 AND confirmed_at IS NULL`
+
+	result, err := tx.Exec(query, time.Now(), step, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("no pending two-factor enrollment")
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseStep records a successfully used time step. It fails if the step is not
+// newer than the last one used, so a code can't be replayed.
+func (r *TOTPRepository) UseStep(userID int64, step int64) error {
+	query := `
+        UPDATE user_totp
+        SET last_used_step = $1
+        WHERE user_id = $2 AND last_used_step < $1`
+
+	result, err := r.DB.Exec(query, step, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("code has already been used")
+	}
+
+	return nil
+}
+
+// UseRecoveryCode consumes an unused recovery code
+func (r *TOTPRepository) UseRecoveryCode(userID int64, codeHash string) error {
+	query := `
+        UPDATE user_recovery_codes
+        SET used_at = $1
+        WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`
+
+	result, err := r.DB.Exec(query, time.Now(), userID, codeHash)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("invalid recovery code")
+	}
+
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left
+func (r *TOTPRepository) CountRecoveryCodes(userID int64) (int, error) {
+	var count int
+	query := `
+        SELECT COUNT(*)
+        FROM user_recovery_codes
+        WHERE user_id = $1 AND used_at IS NULL`
+
+	err := r.DB.QueryRow(query, userID).Scan(&count)
+	return count, err
+}
+
+// Delete disables two-factor authentication and removes the recovery codes
+func (r *TOTPRepository) Delete(userID int64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// replaceRecoveryCodes swaps all recovery codes of a user for new hashes
+func replaceRecoveryCodes(tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO user_recovery_codes (user_id, code_hash)
+        VALUES ($1, $2)`
+
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(query, userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"time"
 
+	"gitlab.com/KARSTERRR/habitrack/internal/dates"
+
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -20,6 +23,27 @@ type User struct {
 	LastLogin      time.Time `json:"last_login"`
 	PasswordResetToken string `json:"-"`
 	PasswordResetExpires time.Time `json:"-"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PendingEmail string `json:"pending_email,omitempty"` // New address awaiting confirmation
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+	Timezone string `json:"timezone"` // IANA time zone name, e.g. "Asia/Tokyo"
+	WeekStart string `json:"week_start"` // "monday", "sunday" or "saturday"
+}
+
+// ErrEmailTaken is returned when an email address belongs to another user
+var ErrEmailTaken = errors.New("email already in use")
+
+// ErrUserNotFound is returned when a user doesn't exist
+var ErrUserNotFound = errors.New("user not found")
+
+// IsEmailVerified reports whether the user has confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// Location returns the user's time zone
+func (u *User) Location() *time.Location {
+	return dates.LoadLocation(u.Timezone)
 }
 
 // HashPassword creates a hashed password from user's password
@@ -58,10 +82,16 @@ func (r *UserRepository) Create(user *User) error {
 	now := time.Now()
 	user.CreatedAt = now
 	user.UpdatedAt = now
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	if user.WeekStart == "" {
+		user.WeekStart = dates.WeekStartMonday
+	}
 
 	query := `
-        INSERT INTO users (username, email, hashed_password, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO users (username, email, hashed_password, created_at, updated_at, timezone, week_start)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
         RETURNING id`
 	
 	err := r.DB.QueryRow(
@@ -71,65 +101,74 @@ func (r *UserRepository) Create(user *User) error {
 		user.HashedPassword,
 		user.CreatedAt,
 		user.UpdatedAt,
+		user.Timezone,
+		user.WeekStart,
 	).Scan(&user.ID)
 	
 	return err
 }
 
-// GetByID retrieves a user by ID
-func (r *UserRepository) GetByID(id int64) (*User, error) {
+// userColumns lists the columns read by scanUser
+const userColumns = `id, username, email, hashed_password, created_at, updated_at, last_login,
+            email_verified_at, pending_email, deletion_scheduled_at, timezone, week_start`
+
+// scanUser scans a single user row selected with userColumns
+func scanUser(row *sql.Row) (*User, error) {
 	user := &User{}
-	query := `
-        SELECT id, username, email, hashed_password, created_at, updated_at, last_login
-        FROM users
-        WHERE id = $1`
-	
-	err := r.DB.QueryRow(query, id).Scan(
+	var lastLogin, emailVerifiedAt, deletionScheduledAt sql.NullTime
+	var pendingEmail sql.NullString
+	err := row.Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.HashedPassword,
 		&user.CreatedAt,
 		&user.UpdatedAt,
-		&user.LastLogin,
+		&lastLogin,
+		&emailVerifiedAt,
+		&pendingEmail,
+		&deletionScheduledAt,
+		&user.Timezone,
+		&user.WeekStart,
 	)
-	
+
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
-	
+
+	user.LastLogin = lastLogin.Time
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+	user.PendingEmail = pendingEmail.String
+	if deletionScheduledAt.Valid {
+		user.DeletionScheduledAt = &deletionScheduledAt.Time
+	}
+
 	return user, nil
 }
 
+// GetByID retrieves a user by ID
+func (r *UserRepository) GetByID(id int64) (*User, error) {
+	query := `
+        SELECT ` + userColumns + `
+        FROM users
+        WHERE id = $1`
+
+	return scanUser(r.DB.QueryRow(query, id))
+}
+
 // GetByEmail retrieves a user by email
 func (r *UserRepository) GetByEmail(email string) (*User, error) {
-	user := &User{}
 	query := `
-        SELECT id, username, email, hashed_password, created_at, updated_at, last_login
+        SELECT ` + userColumns + `
         FROM users
         WHERE email = $1`
-	
-	err := r.DB.QueryRow(query, email).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.HashedPassword,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.LastLogin,
-	)
-	
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
-		}
-		return nil, err
-	}
-	
-	return user, nil
+
+	return scanUser(r.DB.QueryRow(query, email))
 }
 
 // UpdatePassword updates a user's password
@@ -155,40 +194,239 @@ func (r *UserRepository) UpdateLastLogin(userID int64) error {
 	return err
 }
 
-// UpdatePasswordResetToken sets a password reset token for a user
-func (r *UserRepository) UpdatePasswordResetToken(email string, token string, expires time.Time) error {
+// UpdatePasswordResetToken sets the hash of a password reset token for a user
+func (r *UserRepository) UpdatePasswordResetToken(email string, tokenHash string, expires time.Time) error {
 	query := `
         UPDATE users
         SET password_reset_token = $1, password_reset_expires = $2
         WHERE email = $3`
 	
-	_, err := r.DB.Exec(query, token, expires, email)
+	_, err := r.DB.Exec(query, tokenHash, expires, email)
 	return err
 }
 
-// GetByResetToken retrieves a user by password reset token
-func (r *UserRepository) GetByResetToken(token string) (*User, error) {
-	user := &User{}
+// ResetPasswordWithToken sets a new password for the user holding an
+// unexpired reset token hash and clears the token so it can't be used again
+func (r *UserRepository) ResetPasswordWithToken(tokenHash string, hashedPassword string) (int64, error) {
+	var userID int64
+	now := time.Now()
 	query := `
-        SELECT id, username, email, hashed_password, password_reset_token, password_reset_expires
+        UPDATE users
+        SET hashed_password = $1, updated_at = $2,
+            password_reset_token = NULL, password_reset_expires = NULL
+        WHERE password_reset_token = $3 AND password_reset_expires > $2
+        RETURNING id`
+
+	err := r.DB.QueryRow(query, hashedPassword, now, tokenHash).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("invalid or expired reset token")
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// SetEmailVerificationToken stores the hash of an email verification token
+func (r *UserRepository) SetEmailVerificationToken(userID int64, tokenHash string, expires time.Time) error {
+	query := `
+        UPDATE users
+        SET email_verification_token = $1, email_verification_expires = $2
+        WHERE id = $3`
+
+	_, err := r.DB.Exec(query, tokenHash, expires, userID)
+	return err
+}
+
+// VerifyEmailWithToken marks the email of the user holding an unexpired
+// verification token hash as verified and clears the token
+func (r *UserRepository) VerifyEmailWithToken(tokenHash string) (int64, error) {
+	var userID int64
+	now := time.Now()
+	query := `
+        UPDATE users
+        SET email_verified_at = $1, updated_at = $1,
+            email_verification_token = NULL, email_verification_expires = NULL
+        WHERE email_verification_token = $2 AND email_verification_expires > $1
+        RETURNING id`
+
+	err := r.DB.QueryRow(query, now, tokenHash).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("invalid or expired verification token")
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// IsEmailVerified reports whether a user's email address has been verified
+func (r *UserRepository) IsEmailVerified(userID int64) (bool, error) {
+	var verified bool
+	query := `
+        SELECT email_verified_at IS NOT NULL
         FROM users
-        WHERE password_reset_token = $1 AND password_reset_expires > $2`
-	
-	err := r.DB.QueryRow(query, token, time.Now()).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.HashedPassword,
-		&user.PasswordResetToken,
-		&user.PasswordResetExpires,
-	)
-	
+        WHERE id = $1`
+
+	err := r.DB.QueryRow(query, userID).Scan(&verified)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("invalid or expired reset token")
+			return false, ErrUserNotFound
+		}
+		return false, err
+	}
+
+	return verified, nil
+}
+
+// MarkEmailVerified marks a user's email address as verified
+func (r *UserRepository) MarkEmailVerified(userID int64) error {
+	now := time.Now()
+	query := `
+        UPDATE users
+        SET email_verified_at = $1, updated_at = $1,
+            email_verification_token = NULL, email_verification_expires = NULL
+        WHERE id = $2 AND email_verified_at IS NULL`
+
+	_, err := r.DB.Exec(query, now, userID)
+	return err
+}
+
+// UpdateUsername changes a user's display name
+func (r *UserRepository) UpdateUsername(userID int64, username string) error {
+	query := `
+        UPDATE users
+        SET username = $1, updated_at = $2
+        WHERE id = $3`
+
+	result, err := r.DB.Exec(query, username, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UpdateLocale changes a user's time zone and first day of the week
+func (r *UserRepository) UpdateLocale(userID int64, timezone string, weekStart string) error {
+	query := `
+        UPDATE users
+        SET timezone = $1, week_start = $2, updated_at = $3
+        WHERE id = $4`
+
+	_, err := r.DB.Exec(query, timezone, weekStart, time.Now(), userID)
+	return err
+}
+
+// GetLocation returns the time zone of a user
+func (r *UserRepository) GetLocation(userID int64) (*time.Location, error) {
+	var timezone string
+	err := r.DB.QueryRow("SELECT timezone FROM users WHERE id = $1", userID).Scan(&timezone)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
-	
-	return user, nil
-}
\ No newline at end of file
+
+	return dates.LoadLocation(timezone), nil
+}
+
+// SetPendingEmail stores a requested email address together with the hash
+// of the token that confirms it. The current address stays in use until then.
+func (r *UserRepository) SetPendingEmail(userID int64, email string, tokenHash string, expires time.Time) error {
+	query := `
+        UPDATE users
+        SET pending_email = $1, email_change_token = $2, email_change_expires = $3
+        WHERE id = $4`
+
+	_, err := r.DB.Exec(query, email, tokenHash, expires, userID)
+	return err
+}
+
+// ConfirmEmailChange switches the user holding an unexpired email change
+// token hash to their pending address, which counts as verified since the
+// token was sent there. Returns ErrEmailTaken if another account registered
+// the address in the meantime.
+func (r *UserRepository) ConfirmEmailChange(tokenHash string) (int64, error) {
+	var userID int64
+	now := time.Now()
+	query := `
+        UPDATE users
+        SET email = pending_email, email_verified_at = $1, updated_at = $1,
+            pending_email = NULL, email_change_token = NULL, email_change_expires = NULL,
+            email_verification_token = NULL, email_verification_expires = NULL
+        WHERE email_change_token = $2 AND email_change_expires > $1
+        RETURNING id`
+
+	err := r.DB.QueryRow(query, now, tokenHash).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("invalid or expired email change token")
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return 0, ErrEmailTaken
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// ScheduleDeletion marks a user's account for deletion at the given time
+func (r *UserRepository) ScheduleDeletion(userID int64, at time.Time) error {
+	query := `
+        UPDATE users
+        SET deletion_scheduled_at = $1, updated_at = $2
+        WHERE id = $3`
+
+	_, err := r.DB.Exec(query, at, time.Now(), userID)
+	return err
+}
+
+// CancelDeletion clears a scheduled account deletion
+func (r *UserRepository) CancelDeletion(userID int64) error {
+	query := `
+        UPDATE users
+        SET deletion_scheduled_at = NULL, updated_at = $1
+        WHERE id = $2 AND deletion_scheduled_at IS NOT NULL`
+
+	result, err := r.DB.Exec(query, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("no deletion scheduled")
+	}
+
+	return nil
+}
+
+// DeleteScheduled deletes every account whose deletion is due. Their data
+// goes with them through the ON DELETE CASCADE foreign keys.
+func (r *UserRepository) DeleteScheduled(now time.Time) (int64, error) {
+	result, err := r.DB.Exec("DELETE FROM users WHERE deletion_scheduled_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -6,25 +6,62 @@ import (
 
 	"gitlab.com/KARSTERRR/habitrack/config"
 	"gitlab.com/KARSTERRR/habitrack/internal/handlers"
+	"gitlab.com/KARSTERRR/habitrack/internal/mailer"
 	"gitlab.com/KARSTERRR/habitrack/internal/middleware"
+	"gitlab.com/KARSTERRR/habitrack/internal/oidc"
+	"gitlab.com/KARSTERRR/habitrack/internal/throttle"
+	"gitlab.com/KARSTERRR/habitrack/models"
+	"gitlab.com/KARSTERRR/habitrack/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutes configures all API routes
-func SetupRoutes(router *gin.Engine, db *sql.DB) {
+func SetupRoutes(router *gin.Engine, db *sql.DB) error {
 	// Load configuration
 	cfg := config.LoadConfig()
 
+	// Load token signing keys; a missing key set is only papered over with
+	// an ephemeral key outside release mode or when explicitly allowed
+	allowEphemeral := cfg.JWTEphemeralKeys || gin.Mode() != gin.ReleaseMode
+	keys, err := utils.LoadKeySet(cfg.JWTKeysDir, cfg.JWTActiveKeyID, allowEphemeral)
+	if err != nil {
+		return err
+	}
+
+	// Create mailer and brute-force limiter
+	mail := mailer.New(cfg)
+	limiter := throttle.New(cfg, db)
+
 	// Create handlers
-	userHandler := handlers.NewUserHandler(db, cfg)
+	userHandler := handlers.NewUserHandler(db, cfg, mail, keys, limiter)
 	habitHandler := handlers.NewHabitHandler(db)
+	dashboardHandler := handlers.NewDashboardHandler(db)
+	heatmapHandler := handlers.NewHeatmapHandler(db)
+	pauseHandler := handlers.NewPauseHandler(db)
+	freezeHandler := handlers.NewFreezeHandler(db)
+	sessionHandler := handlers.NewSessionHandler(db, cfg)
+	twoFactorHandler := handlers.NewTwoFactorHandler(db, cfg, userHandler)
+	oidcHandler := handlers.NewOIDCHandler(db, cfg, oidc.NewProviders(cfg), userHandler)
+	profileHandler := handlers.NewProfileHandler(db, cfg, userHandler)
+	accessTokenHandler := handlers.NewAccessTokenHandler(db, cfg)
+	jwksHandler := handlers.NewJWKSHandler(keys)
+
+	// Create middleware
+	authMiddleware := middleware.AuthMiddleware(db, keys)
+	habitsRead := middleware.RequireScope(models.ScopeHabitsRead)
+	habitsWrite := middleware.RequireScope(models.ScopeHabitsWrite)
+	trackingRead := middleware.RequireScope(models.ScopeTrackingRead)
+	trackingWrite := middleware.RequireScope(models.ScopeTrackingWrite)
 
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "OK", "message": "API is running"})
 	})
 
+	// Public token verification keys
+	router.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
 	// API version group
 	v1 := router.Group("/api/v1")
 	{
@@ -33,32 +70,97 @@ func SetupRoutes(router *gin.Engine, db *sql.DB) {
 		{
 			auth.POST("/register", userHandler.RegisterUser)
 			auth.POST("/login", userHandler.LoginUser)
+			auth.POST("/login/mfa", userHandler.VerifyMFALogin)
 			auth.POST("/refresh", userHandler.RefreshToken)
 			auth.POST("/forgot-password", userHandler.RequestPasswordReset)
 			auth.POST("/reset-password", userHandler.ResetPassword)
+			auth.POST("/verify-email", userHandler.VerifyEmail)
+			auth.POST("/resend-verification", userHandler.ResendVerification)
+			auth.POST("/confirm-email-change", profileHandler.ConfirmEmailChange)
+			auth.POST("/logout", authMiddleware, sessionHandler.Logout)
+
+			// OpenID Connect login
+			auth.GET("/oidc/providers", oidcHandler.ListProviders)
+			auth.POST("/oidc/:provider/start", oidcHandler.StartLogin)
+			auth.POST("/oidc/:provider/callback", oidcHandler.CompleteLogin)
 		}
 
 		// Protected routes (auth required)
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(cfg))
+		protected.Use(authMiddleware)
 		{
 			// User routes
 			protected.GET("/user/me", userHandler.GetCurrentUser)
 
+			// Account management routes (personal access tokens not accepted)
+			account := protected.Group("/user")
+			account.Use(middleware.RequireSession())
+			{
+				// Profile management
+				account.PATCH("/me", profileHandler.UpdateProfile)
+				account.POST("/me/password", profileHandler.ChangePassword)
+				account.POST("/me/email", profileHandler.ChangeEmail)
+				account.DELETE("/me", profileHandler.DeleteAccount)
+				account.POST("/me/cancel-deletion", profileHandler.CancelDeletion)
+
+				account.GET("/sessions", sessionHandler.ListSessions)
+				account.DELETE("/sessions/:id", sessionHandler.RevokeSession)
+				account.GET("/2fa", twoFactorHandler.GetStatus)
+				account.POST("/2fa/setup", twoFactorHandler.Setup)
+				account.POST("/2fa/confirm", twoFactorHandler.Confirm)
+				account.POST("/2fa/disable", twoFactorHandler.Disable)
+
+				// Personal access tokens
+				account.POST("/tokens", accessTokenHandler.CreateToken)
+				account.GET("/tokens", accessTokenHandler.ListTokens)
+				account.GET("/tokens/:id", accessTokenHandler.GetToken)
+				account.PATCH("/tokens/:id", accessTokenHandler.UpdateToken)
+				account.DELETE("/tokens/:id", accessTokenHandler.RevokeToken)
+			}
+
+			// Routes restricted for unverified email addresses
+			verified := protected.Group("")
+			verified.Use(middleware.RequireVerifiedEmail(db, cfg))
+
+			// Home screen summary across habits
+			verified.GET("/dashboard", habitsRead, trackingRead, dashboardHandler.GetDashboard)
+			verified.GET("/heatmap", trackingRead, heatmapHandler.GetHeatmap)
+
+			// Vacation mode and pause history
+			verified.GET("/pauses", habitsRead, pauseHandler.ListPauses)
+			verified.POST("/pauses", habitsWrite, pauseHandler.CreatePause)
+			verified.POST("/pauses/:id/end", habitsWrite, pauseHandler.EndPause)
+			verified.DELETE("/pauses/:id", habitsWrite, pauseHandler.DeletePause)
+
+			// Streak freezes earned and spent
+			verified.GET("/freezes", trackingRead, freezeHandler.GetFreezes)
+
 			// Habit routes
-			habits := protected.Group("/habits")
+			habits := verified.Group("/habits")
 			{
-				habits.POST("", habitHandler.CreateHabit)
-				habits.GET("", habitHandler.ListHabits)
-				habits.GET("/:id", habitHandler.GetHabit)
-				habits.PUT("/:id", habitHandler.UpdateHabit)
-				habits.DELETE("/:id", habitHandler.DeleteHabit)
-				
+				habits.POST("", habitsWrite, habitHandler.CreateHabit)
+				habits.GET("", habitsRead, habitHandler.ListHabits)
+				habits.GET("/:id", habitsRead, habitHandler.GetHabit)
+				habits.PUT("/:id", habitsWrite, habitHandler.UpdateHabit)
+				habits.PATCH("/:id", habitsWrite, habitHandler.PatchHabit)
+				habits.DELETE("/:id", habitsWrite, habitHandler.DeleteHabit)
+				habits.POST("/:id/archive", habitsWrite, habitHandler.ArchiveHabit)
+				habits.POST("/:id/unarchive", habitsWrite, habitHandler.UnarchiveHabit)
+
 				// Habit tracking
-				habits.POST("/:id/track", habitHandler.TrackHabit)
-				habits.GET("/:id/tracking", habitHandler.GetHabitTracking)
-				habits.GET("/:id/stats", habitHandler.GetHabitStats)
+				habits.POST("/:id/track", trackingWrite, habitHandler.TrackHabit)
+				habits.GET("/:id/tracking", trackingRead, habitHandler.GetHabitTracking)
+				habits.GET("/:id/events", trackingRead, habitHandler.ListHabitEvents)
+				habits.DELETE("/:id/events/:eventId", trackingWrite, habitHandler.DeleteHabitEvent)
+				habits.GET("/:id/stats", trackingRead, habitHandler.GetHabitStats)
+				habits.GET("/:id/heatmap", trackingRead, heatmapHandler.GetHabitHeatmap)
+
+				// Habit pauses
+				habits.GET("/:id/pauses", habitsRead, pauseHandler.ListHabitPauses)
+				habits.POST("/:id/pauses", habitsWrite, pauseHandler.CreateHabitPause)
 			}
 		}
 	}
+
+	return nil
 }
\ No newline at end of file
@@ -1,43 +1,107 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 
 	"gitlab.com/KARSTERRR/habitrack/models"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 // Claims is a struct that will be encoded to a JWT.
 type Claims struct {
-	UserID int64 `json:"user_id"`
-	jwt.StandardClaims
+	UserID    int64  `json:"user_id"`
+	SessionID int64  `json:"sid"`
+	Purpose   string `json:"purpose,omitempty"` // Empty for access tokens
+	jwt.RegisteredClaims
 }
 
-// GenerateJWT generates a JWT token for a user
-func GenerateJWT(user *models.User, secret string, expiration time.Duration) (string, error) {
+// MFAPurpose marks tokens that only prove the password step of a login
+const MFAPurpose = "mfa"
+
+// tokenIssuer is the iss claim of every token we sign
+const tokenIssuer = "habitrack"
+
+// GenerateJWT generates a JWT token for a user session
+func GenerateJWT(user *models.User, sessionID int64, keys *KeySet, expiration time.Duration) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    tokenIssuer,
+			Subject:   user.Email,
+		},
+	}
+
+	return keys.Sign(claims)
+}
+
+// ValidateJWT validates an access token
+func ValidateJWT(tokenString string, keys *KeySet) (*Claims, error) {
+	claims, err := parseJWT(tokenString, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+// GenerateMFAToken generates a short-lived token for a user who passed the
+// password check and still has to present a second factor
+func GenerateMFAToken(user *models.User, keys *KeySet, expiration time.Duration) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID: user.ID,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(expiration).Unix(),
-			IssuedAt:  time.Now().Unix(),
-			Issuer:    "habitrack",
+		UserID:  user.ID,
+		Purpose: MFAPurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    tokenIssuer,
 			Subject:   user.Email,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return keys.Sign(claims)
+}
+
+// ValidateMFAToken validates an MFA challenge token
+func ValidateMFAToken(tokenString string, keys *KeySet) (*Claims, error) {
+	claims, err := parseJWT(tokenString, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != MFAPurpose {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
 }
 
-// ValidateJWT validates a JWT token
-func ValidateJWT(tokenString string, secret string) (*Claims, error) {
+// parseJWT parses and verifies a signed token
+func parseJWT(tokenString string, keys *KeySet) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		keys.Keyfunc,
+		jwt.WithValidMethods(keys.Algorithms()),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
@@ -50,18 +114,17 @@ func ValidateJWT(tokenString string, secret string) (*Claims, error) {
 	return claims, nil
 }
 
-// GenerateRefreshToken generates a refresh token for a user
-func GenerateRefreshToken(user *models.User, secret string, expiration time.Duration) (string, error) {
-	claims := &Claims{
-		UserID: user.ID,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(expiration).Unix(),
-			IssuedAt:  time.Now().Unix(),
-			Issuer:    "habitrack",
-			Subject:   "refresh",
-		},
+// GenerateRandomToken returns a URL-safe random token built from n random bytes
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+// HashToken returns the hex-encoded SHA-256 hash of a token for storage
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is one key of the token signing key set
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer // Nil for keys kept only to verify older tokens
+	PublicKey  crypto.PublicKey
+}
+
+// KeySet holds the keys used to sign and verify tokens. One key signs new
+// tokens; every key in the set verifies, so tokens signed by a previous key
+// stay valid while a rotation is in progress.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// LoadKeySet reads PEM keys from dir. Each file's name without extension is
+// its key ID. Private keys (RSA or Ed25519, PKCS#8 or PKCS#1) can sign;
+// public keys are kept for verification only. activeID picks the signing
+// key, defaulting to the private key whose ID sorts last. When dir has no
+// keys and allowEphemeral is set an ephemeral Ed25519 key is generated,
+// which is only suitable for local development; otherwise it's an error.
+func LoadKeySet(dir string, activeID string, allowEphemeral bool) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := parseSigningKey(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("could not load signing key %s: %w", file, err)
+		}
+
+		ks.keys[id] = key
+	}
+
+	if len(ks.keys) == 0 {
+		if !allowEphemeral {
+			return nil, fmt.Errorf("no signing keys found in %q", dir)
+		}
+		log.Printf("No signing keys found in %q, generating an ephemeral key; tokens will not survive a restart", dir)
+		return NewEphemeralKeySet()
+	}
+
+	if activeID == "" {
+		ids := make([]string, 0, len(ks.keys))
+		for id, key := range ks.keys {
+			if key.PrivateKey != nil {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		if len(ids) > 0 {
+			activeID = ids[len(ids)-1]
+		}
+	}
+
+	active, ok := ks.keys[activeID]
+	if !ok || active.PrivateKey == nil {
+		return nil, fmt.Errorf("no private signing key with ID %q", activeID)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+// NewEphemeralKeySet creates a key set with a single freshly generated
+// Ed25519 key
+func NewEphemeralKeySet() (*KeySet, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := GenerateRandomToken(8)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, PrivateKey: priv, PublicKey: pub}
+	return &KeySet{active: key, keys: map[string]*SigningKey{id: key}}, nil
+}
+
+// Sign signs claims with the active key and sets the kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.PrivateKey)
+}
+
+// Keyfunc looks up the verification key for a token by its kid header and
+// checks that the token's algorithm matches the key
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	return key.PublicKey, nil
+}
+
+// Algorithms lists the signing algorithms used by the set
+func (ks *KeySet) Algorithms() []string {
+	seen := make(map[string]bool)
+	algs := make([]string, 0)
+	for _, key := range ks.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// JWKS returns the public keys of the set in JWKS format
+func (ks *KeySet) JWKS() []JWK {
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := make([]JWK, 0, len(ids))
+	for _, id := range ids {
+		key := ks.keys[id]
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		jwks = append(jwks, jwk)
+	}
+
+	return jwks
+}
+
+// parseSigningKey parses a PEM-encoded private or public key
+func parseSigningKey(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, PrivateKey: k, PublicKey: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, PublicKey: k}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, PrivateKey: k, PublicKey: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, PublicKey: k}, nil
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to a JSON document and
+// returns the patched document. Members set to null in the patch are
+// removed, objects merge recursively and any other value replaces the
+// target's.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target, changes any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, err
+	}
+
+	if _, ok := changes.(map[string]any); !ok {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+
+	return json.Marshal(mergeValue(target, changes))
+}
+
+// mergeValue merges patch into target following RFC 7396
+func mergeValue(target, patch any) any {
+	changes, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	merged, ok := target.(map[string]any)
+	if !ok {
+		merged = make(map[string]any)
+	}
+
+	for key, value := range changes {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = mergeValue(merged[key], value)
+	}
+	return merged
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"encoding/base32"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// The RFC 6238 appendix B SHA-1 vectors, cut to the last six digits
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for _, tt := range rfc6238Vectors {
+		if got := totpCode(key, tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		step, ok := ValidateTOTP(rfc6238Secret, tt.code, time.Unix(tt.unix, 0))
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("ValidateTOTP(%s) at %d = %d, %v; want %d, true", tt.code, tt.unix, step, ok, tt.unix/totpPeriod)
+		}
+	}
+
+	at := time.Unix(1111111111, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		at     time.Time
+		want   bool
+	}{
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "050471", at, true},
+		{"surrounding spaces", rfc6238Secret, " 050471 ", at, true},
+		{"previous period", rfc6238Secret, "050471", at.Add(totpPeriod * time.Second), true},
+		{"next period", rfc6238Secret, "050471", at.Add(-totpPeriod * time.Second), true},
+		{"two periods late", rfc6238Secret, "050471", at.Add(2 * totpPeriod * time.Second), false},
+		{"wrong code", rfc6238Secret, "050472", at, false},
+		{"too short", rfc6238Secret, "05047", at, false},
+		{"eight digits", rfc6238Secret, "14050471", at, false},
+		{"invalid secret", "not base32!", "050471", at, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTOTP(tt.secret, tt.code, tt.at); ok != tt.want {
+				t.Errorf("ValidateTOTP = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	format := regexp.MustCompile(`^[abcdefghjkmnpqrstuvwxyz23456789]{5}-[abcdefghjkmnpqrstuvwxyz23456789]{5}$`)
+
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q doesn't match xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q issued twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"abcde-fghjk", "abcdefghjk"},
+		{"ABCDE-FGHJK", "abcdefghjk"},
+		{" abcde fghjk ", "abcdefghjk"},
+		{"abcdefghjk", "abcdefghjk"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeRecoveryCode(tt.code); got != tt.want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}